@@ -0,0 +1,203 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ANSI handling modes.
+const (
+	ANSIKeep  = "keep"  // send escape sequences unchanged
+	ANSIStrip = "strip" // remove escape sequences and apply \r overwrites
+	ANSIColor = "color" // convert SGR colors into an ```ansi code block
+)
+
+// ansiPattern matches CSI sequences, OSC strings and two-byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// sgrState is the subset of terminal text attributes Discord's ansi code
+// blocks can render. Colors are stored as their SGR codes, 0 meaning default.
+type sgrState struct {
+	bold      bool
+	underline bool
+	fg        int
+	bg        int
+}
+
+// apply updates the state from the parameters of an SGR (ESC[...m) sequence.
+func (s *sgrState) apply(params string) {
+	if params == "" {
+		*s = sgrState{}
+		return
+	}
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		n, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case n == 0:
+			*s = sgrState{}
+		case n == 1:
+			s.bold = true
+		case n == 22:
+			s.bold = false
+		case n == 4:
+			s.underline = true
+		case n == 24:
+			s.underline = false
+		case n >= 30 && n <= 37:
+			s.fg = n
+		case n == 39:
+			s.fg = 0
+		case n >= 40 && n <= 47:
+			s.bg = n
+		case n == 49:
+			s.bg = 0
+		case n >= 90 && n <= 97:
+			// Bright colors fall back to their normal variant
+			s.fg = n - 60
+		case n >= 100 && n <= 107:
+			s.bg = n - 60
+		case n == 38 || n == 48:
+			// 256-color and truecolor can't be shown, skip their arguments
+			if i+1 < len(codes) && codes[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(codes) && codes[i+1] == "2" {
+				i += 4
+			}
+		}
+	}
+}
+
+// sequence returns the SGR sequence that sets exactly this state.
+func (s sgrState) sequence() string {
+	params := []string{"0"}
+	if s.bold {
+		params = append(params, "1")
+	}
+	if s.underline {
+		params = append(params, "4")
+	}
+	if s.fg != 0 {
+		params = append(params, strconv.Itoa(s.fg))
+	}
+	if s.bg != 0 {
+		params = append(params, strconv.Itoa(s.bg))
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// trackSGR returns the state after the SGR sequences in text.
+func trackSGR(state sgrState, text string) sgrState {
+	for _, seq := range ansiPattern.FindAllString(text, -1) {
+		if strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m") {
+			state.apply(seq[2 : len(seq)-1])
+		}
+	}
+	return state
+}
+
+// termCell is one character on a rendered line.
+type termCell struct {
+	r     rune
+	style sgrState
+}
+
+// renderTerminal replays text the way a terminal would show it: \r returns
+// to the start of the line so progress updates overwrite each other, \b
+// moves back one column, ESC[K clears the rest of the line and other escape
+// sequences are dropped. With color, SGR attributes are kept as minimal
+// sequences; otherwise the result is plain text.
+func renderTerminal(text string, color bool) (string, bool) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var out strings.Builder
+	var style, emitted sgrState
+	colored := false
+	for li, line := range lines {
+		var cells []termCell
+		cursor := 0
+		write := func(r rune) {
+			switch r {
+			case '\r':
+				cursor = 0
+			case '\b':
+				if cursor > 0 {
+					cursor--
+				}
+			default:
+				cell := termCell{r: r, style: style}
+				if cursor < len(cells) {
+					cells[cursor] = cell
+				} else {
+					cells = append(cells, cell)
+				}
+				cursor++
+			}
+		}
+
+		pos := 0
+		for _, loc := range ansiPattern.FindAllStringIndex(line, -1) {
+			for _, r := range line[pos:loc[0]] {
+				write(r)
+			}
+			seq := line[loc[0]:loc[1]]
+			switch {
+			case strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m"):
+				style.apply(seq[2 : len(seq)-1])
+			case strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "K"):
+				if cursor < len(cells) {
+					cells = cells[:cursor]
+				}
+			}
+			pos = loc[1]
+		}
+		for _, r := range line[pos:] {
+			write(r)
+		}
+
+		if li > 0 {
+			out.WriteByte('\n')
+		}
+		for _, cell := range cells {
+			if color && cell.style != emitted {
+				out.WriteString(cell.style.sequence())
+				emitted = cell.style
+				colored = true
+			}
+			out.WriteRune(cell.r)
+		}
+	}
+	return out.String(), colored
+}
+
+// escapeFences breaks up triple backticks so text can't close the code block
+// it is placed in. A zero-width space keeps the backticks visible.
+func escapeFences(text string) string {
+	return strings.ReplaceAll(text, "```", "`\u200b`\u200b`")
+}
+
+// convertANSI applies the configured ANSI mode to content. In color mode the
+// result is wrapped in an ```ansi block when it contains any colors.
+func convertANSI(content, mode string) (string, error) {
+	switch mode {
+	case "", ANSIKeep:
+		return content, nil
+	case ANSIStrip:
+		text, _ := renderTerminal(content, false)
+		return text, nil
+	case ANSIColor:
+		text, colored := renderTerminal(content, true)
+		if !colored {
+			return text, nil
+		}
+		text = strings.TrimRight(escapeFences(text), "\n")
+		// End on a reset so the closing fence isn't styled
+		return "```ansi\n" + text + "\x1b[0m\n```", nil
+	}
+	return "", configErrorf("invalid ANSI mode %q (expected keep, strip or color)", mode)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestConvertANSIStrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain", "hello\nworld", "hello\nworld"},
+		{"colors", "\x1b[31mFAIL\x1b[0m pkg/foo", "FAIL pkg/foo"},
+		{"progress overwrite", "10%\r50%\r100%\ndone", "100%\ndone"},
+		{"shorter overwrite", "downloading\rok", "okwnloading"},
+		{"erase line", "downloading\r\x1b[Kok", "ok"},
+		{"crlf", "a\r\nb\r\n", "a\nb\n"},
+		{"backspace", "ab\bc", "ac"},
+		{"osc title", "\x1b]0;title\x07text", "text"},
+		{"cursor movement", "\x1b[2Atext\x1b[?25l", "text"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := convertANSI(tc.input, ANSIStrip)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestConvertANSIColor(t *testing.T) {
+	got, err := convertANSI("\x1b[1;91mFAIL\x1b[0m pkg\n\x1b[32mok\x1b[39m done\n", ANSIColor)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "```ansi\n\x1b[0;1;31mFAIL\x1b[0m pkg\n\x1b[0;32mok\x1b[0m done\x1b[0m\n```"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// Without colors there is nothing to convert
+	got, _ = convertANSI("plain\r\n", ANSIColor)
+	if got != "plain\n" {
+		t.Errorf("Expected plain text, got %q", got)
+	}
+
+	got, _ = convertANSI("\x1b[31m```\x1b[0m", ANSIColor)
+	if strings.Count(got, "```") != 2 {
+		t.Errorf("Expected inner fences to be escaped, got %q", got)
+	}
+
+	if _, err := convertANSI("x", "rainbow"); err == nil {
+		t.Error("Expected an invalid mode to be rejected")
+	}
+	if got, _ := convertANSI("\x1b[31mx", ANSIKeep); got != "\x1b[31mx" {
+		t.Errorf("Expected keep to leave content alone, got %q", got)
+	}
+}
+
+func TestSGRApply(t *testing.T) {
+	var s sgrState
+	s.apply("1;4;38;5;208;44")
+	if !s.bold || !s.underline || s.fg != 0 || s.bg != 44 {
+		t.Errorf("Expected bold underline on blue with 256-color skipped, got %+v", s)
+	}
+	s.apply("22;24;49;33")
+	if s != (sgrState{fg: 33}) {
+		t.Errorf("Expected only yellow to remain, got %+v", s)
+	}
+	s.apply("")
+	if s != (sgrState{}) {
+		t.Errorf("Expected an empty SGR to reset, got %+v", s)
+	}
+}
+
+func TestSplitFenced(t *testing.T) {
+	content := "intro\n```go\n" + strings.Repeat("line of code\n", 10) + "```\noutro"
+	parts := splitFenced(content, 60)
+	if len(parts) < 2 {
+		t.Fatalf("Expected content to be split, got %d parts", len(parts))
+	}
+
+	for i, part := range parts {
+		if len(part) > 60 {
+			t.Errorf("Part %d exceeds budget: %d", i, len(part))
+		}
+		if strings.Count(part, "```")%2 != 0 {
+			t.Errorf("Part %d has an unbalanced fence: %q", i, part)
+		}
+		if i > 0 && strings.Contains(part, "line of code") && !strings.HasPrefix(part, "```go\n") {
+			t.Errorf("Part %d should reopen the go block: %q", i, part)
+		}
+	}
+
+	joined := strings.Join(parts, "")
+	if strings.Count(joined, "line of code") != 10 || !strings.HasSuffix(joined, "outro") {
+		t.Errorf("Expected all content to be kept, got %q", joined)
+	}
+}
+
+func TestSplitFencedCarriesANSIColor(t *testing.T) {
+	content, _ := convertANSI("\x1b[31m"+strings.Repeat("red line\n", 8), ANSIColor)
+	parts := splitFenced(content, 50)
+	if len(parts) < 2 {
+		t.Fatalf("Expected content to be split, got %d parts", len(parts))
+	}
+	for i, part := range parts[1:] {
+		if !strings.HasPrefix(part, "```ansi\n\x1b[0;31m") {
+			t.Errorf("Part %d should restore the red color: %q", i+1, part)
+		}
+	}
+}
+
+func TestSplitFencedLongLine(t *testing.T) {
+	content := "```\n" + strings.Repeat("é", 40) + "\n```"
+	parts := splitFenced(content, 30)
+	for i, part := range parts {
+		if len(part) > 30 {
+			t.Errorf("Part %d exceeds budget: %d", i, len(part))
+		}
+		if !strings.HasPrefix(part, "```") || !strings.HasSuffix(part, "```") {
+			t.Errorf("Part %d should be fenced: %q", i, part)
+		}
+	}
+	if got := strings.Count(strings.Join(parts, ""), "é"); got != 40 {
+		t.Errorf("Expected 40 runes kept intact, got %d", got)
+	}
+}
+
+func TestSplitFencedNeverExceedsLimit(t *testing.T) {
+	// Fences only open or close at the start of a line; inline ``` and long
+	// lines that get cut must not throw the part sizes off
+	lines := []string{"```", "```go", "```ansi", "plain text", "a```b", "x ``` y ```", "-"}
+	words := []string{"word", "é", "🚀", "```", "`", " ", "longerword"}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5000; i++ {
+		var b strings.Builder
+		for n := rng.Intn(12); n >= 0; n-- {
+			line := lines[rng.Intn(len(lines))]
+			b.WriteString(line)
+			if strings.HasPrefix(line, "```") {
+				// Fence lines keep a short language
+				b.WriteString("\n")
+				continue
+			}
+			for m := rng.Intn(40); m > 0; m-- {
+				b.WriteString(words[rng.Intn(len(words))])
+			}
+			b.WriteString("\n")
+		}
+		content := b.String()
+		max := 24 + rng.Intn(60)
+		for j, part := range splitFenced(content, max) {
+			if len(part) > max {
+				t.Fatalf("Part %d is %d bytes with a limit of %d:\n%q\nfrom %q", j, len(part), max, part, content)
+			}
+		}
+	}
+}
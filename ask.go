@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultAskTimeout is how long ask waits for an answer when --timeout is
+// unset.
+const DefaultAskTimeout = 10 * time.Minute
+
+// Exit codes for ask.
+const (
+	AskExitApproved = 0
+	AskExitDenied   = 1
+	AskExitTimeout  = 2
+	AskExitError    = 3
+)
+
+// Decisions reported by ask.
+const (
+	DecisionApproved    = "approved"
+	DecisionDenied      = "denied"
+	DecisionTimeout     = "timeout"
+	DecisionInterrupted = "interrupted"
+)
+
+const (
+	askButtonPrefix = "disgo-ask:"
+	askModalPrefix  = "disgo-ask-reason:"
+	askReasonInput  = "reason"
+	approveEmoji    = "✅"
+	denyEmoji       = "❌"
+)
+
+// AskResult is the outcome of an approval request.
+type AskResult struct {
+	Decision    string `json:"decision"`
+	Responder   string `json:"responder,omitempty"`
+	ResponderID string `json:"responder_id,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	ChannelID   string `json:"channel_id"`
+	MessageID   string `json:"message_id"`
+}
+
+// exitCode maps the decision to the documented exit code.
+func (r AskResult) exitCode() int {
+	switch r.Decision {
+	case DecisionApproved:
+		return AskExitApproved
+	case DecisionDenied:
+		return AskExitDenied
+	case DecisionInterrupted:
+		return ExitInterrupted
+	}
+	return AskExitTimeout
+}
+
+// summary is the line appended to the question once it has been answered.
+func (r AskResult) summary() string {
+	var line string
+	switch r.Decision {
+	case DecisionApproved:
+		line = approveEmoji + " Approved by " + r.Responder
+	case DecisionDenied:
+		line = denyEmoji + " Denied by " + r.Responder
+	case DecisionInterrupted:
+		return "🛑 No longer waiting for an answer"
+	default:
+		return "⌛ No answer, timed out"
+	}
+	if r.Reason != "" {
+		line += ": " + r.Reason
+	}
+	return line
+}
+
+// askNonce makes button IDs unique to this request so that old questions
+// left in the channel can't answer a new one.
+func askNonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseAskAction splits a custom ID of the form prefix + action:nonce.
+func parseAskAction(customID, prefix, nonce string) (string, bool) {
+	rest, ok := strings.CutPrefix(customID, prefix)
+	if !ok {
+		return "", false
+	}
+	action, gotNonce, ok := strings.Cut(rest, ":")
+	if !ok || gotNonce != nonce {
+		return "", false
+	}
+	if action != DecisionApproved && action != DecisionDenied {
+		return "", false
+	}
+	return action, true
+}
+
+// runAsk posts a question with approve/deny buttons and waits for an
+// authorized user to answer by button or by reacting with ✅ or ❌.
+func (c *CLI) runAsk() error {
+	err := c.ask()
+	var exitErr *exitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	// Keep failures apart from a denial
+	return &exitError{code: AskExitError, err: err}
+}
+
+func (c *CLI) ask() error {
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+
+	question := strings.TrimSpace(strings.Join(c.args, " "))
+	if question == "" {
+		question = strings.TrimSpace(string(c.stdinData))
+	}
+	if question == "" {
+		return configErrorf("usage: disgo ask <question>")
+	}
+
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = DefaultAskTimeout
+	}
+
+	var approvers []Mention
+	for _, spec := range c.parseTags(strings.Join(c.approvers, ",")) {
+		if spec == "" {
+			continue
+		}
+		m, err := parseMention(spec)
+		if err != nil {
+			return err
+		}
+		if m.Kind != MentionUser && m.Kind != MentionRole {
+			return fmt.Errorf("approvers must be user:ID or role:ID, got %q", spec)
+		}
+		approvers = append(approvers, m)
+	}
+	if len(approvers) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no --approvers set, anyone who can see the channel may answer")
+	}
+
+	discord, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	// Approvers are pinged so they notice the request
+	prefix, allowed, err := resolveMentions(discord, c.config.ServerID, approvers)
+	if err != nil {
+		return err
+	}
+	authorized := newApproverSet(allowed.Users, allowed.Roles)
+
+	nonce, err := askNonce()
+	if err != nil {
+		return err
+	}
+	results := make(chan AskResult, 1)
+	decide := func(r AskResult) {
+		select {
+		case results <- r:
+		default:
+		}
+	}
+
+	// Reactions are matched against the question once it has been posted
+	var messageID atomic.Value
+	messageID.Store("")
+	discord.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		c.handleAskInteraction(s, i, nonce, authorized, decide)
+	})
+	discord.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		if r.MessageID != messageID.Load().(string) || (s.State.User != nil && r.UserID == s.State.User.ID) {
+			return
+		}
+		var decision string
+		switch r.Emoji.Name {
+		case approveEmoji:
+			decision = DecisionApproved
+		case denyEmoji:
+			decision = DecisionDenied
+		default:
+			return
+		}
+		var roles []string
+		name := r.UserID
+		if r.Member != nil {
+			roles = r.Member.Roles
+			if r.Member.User != nil {
+				name = r.Member.User.Username
+			}
+		}
+		if !authorized.allows(r.UserID, roles) {
+			if c.config.Debug {
+				log.Printf("Ignoring reaction from unauthorized user %s", name)
+			}
+			return
+		}
+		decide(AskResult{Decision: decision, Responder: name, ResponderID: r.UserID, Reason: "reacted " + r.Emoji.Name})
+	})
+
+	if err := c.openGateway(discord, discordgo.IntentsGuildMessageReactions|discordgo.IntentsDirectMessageReactions); err != nil {
+		return err
+	}
+
+	content := question
+	if prefix != "" {
+		content = prefix + "\n" + question
+	}
+	msg, err := discord.ChannelMessageSendComplex(c.config.ChannelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: allowed,
+		Components:      askButtons(nonce, false),
+	})
+	if err != nil {
+		return fmt.Errorf("error sending question: %w", err)
+	}
+	messageID.Store(msg.ID)
+
+	// Offer the reactions to click; the buttons still work without them
+	for _, emoji := range []string{approveEmoji, denyEmoji} {
+		if err := discord.MessageReactionAdd(msg.ChannelID, msg.ID, emoji); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not add %s to the question: %v\n", emoji, err)
+		}
+	}
+
+	if c.config.Debug {
+		log.Printf("Waiting up to %s for an answer to message %s", timeout, msg.ID)
+	}
+
+	// An interrupted wait still disables the buttons, so nobody answers a
+	// question no one is waiting on. A second signal exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	var result AskResult
+	select {
+	case result = <-results:
+	case <-time.After(timeout):
+		result = AskResult{Decision: DecisionTimeout}
+	case <-ctx.Done():
+		result = AskResult{Decision: DecisionInterrupted}
+	}
+	stop()
+	result.ChannelID = msg.ChannelID
+	result.MessageID = msg.ID
+
+	// Record the outcome on the question and disable the buttons
+	edit := discordgo.NewMessageEdit(msg.ChannelID, msg.ID).SetContent(content + "\n" + result.summary())
+	edit.AllowedMentions = noMentions()
+	buttons := askButtons(nonce, true)
+	edit.Components = &buttons
+	if _, err := discord.ChannelMessageEditComplex(edit); err != nil && c.config.Debug {
+		log.Printf("Failed to update question with the outcome: %v", err)
+	}
+
+	if err := c.printAskResult(result); err != nil {
+		return err
+	}
+	if code := result.exitCode(); code != AskExitApproved {
+		return &exitError{code: code}
+	}
+	return nil
+}
+
+func askButtons(nonce string, disabled bool) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Approve",
+				Style:    discordgo.SuccessButton,
+				CustomID: askButtonPrefix + DecisionApproved + ":" + nonce,
+				Disabled: disabled,
+			},
+			discordgo.Button{
+				Label:    "Deny",
+				Style:    discordgo.DangerButton,
+				CustomID: askButtonPrefix + DecisionDenied + ":" + nonce,
+				Disabled: disabled,
+			},
+		}},
+	}
+}
+
+// handleAskInteraction answers a button press with a modal asking for an
+// optional reason, and records the decision when the modal is submitted.
+func (c *CLI) handleAskInteraction(s *discordgo.Session, i *discordgo.InteractionCreate, nonce string, authorized approverSet, decide func(AskResult)) {
+	user, roles := interactionUser(i)
+	if user == nil {
+		return
+	}
+
+	var action string
+	var ok bool
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		action, ok = parseAskAction(i.MessageComponentData().CustomID, askButtonPrefix, nonce)
+	case discordgo.InteractionModalSubmit:
+		action, ok = parseAskAction(i.ModalSubmitData().CustomID, askModalPrefix, nonce)
+	}
+	if !ok {
+		return
+	}
+
+	if !authorized.allows(user.ID, roles) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "You are not allowed to answer this request.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	if i.Type == discordgo.InteractionMessageComponent {
+		title := "Approve"
+		if action == DecisionDenied {
+			title = "Deny"
+		}
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: askModalPrefix + action + ":" + nonce,
+				Title:    title,
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  askReasonInput,
+							Label:     "Reason (optional)",
+							Style:     discordgo.TextInputParagraph,
+							Required:  false,
+							MaxLength: 500,
+						},
+					}},
+				},
+			},
+		})
+		if err != nil && c.config.Debug {
+			log.Printf("Failed to open reason dialog: %v", err)
+		}
+		return
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	decide(AskResult{
+		Decision:    action,
+		Responder:   user.Username,
+		ResponderID: user.ID,
+		Reason:      modalReason(i.ModalSubmitData()),
+	})
+}
+
+// modalReason extracts the reason text input from a submitted modal.
+func modalReason(data discordgo.ModalSubmitInteractionData) string {
+	for _, row := range data.Components {
+		actions, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, comp := range actions.Components {
+			if input, ok := comp.(*discordgo.TextInput); ok && input.CustomID == askReasonInput {
+				return strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// printAskResult prints the decision, responder and reason.
+func (c *CLI) printAskResult(r AskResult) error {
+	if c.config.Output == OutputJSON {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("error encoding result: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	line := r.Decision
+	if r.Responder != "" {
+		line += " by " + r.Responder + " (" + r.ResponderID + ")"
+	}
+	if r.Reason != "" {
+		line += ": " + r.Reason
+	}
+	fmt.Fprintln(os.Stdout, line)
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseAskAction(t *testing.T) {
+	testCases := []struct {
+		customID string
+		expected string
+		ok       bool
+	}{
+		{customID: askButtonPrefix + "approved:abc", expected: DecisionApproved, ok: true},
+		{customID: askButtonPrefix + "denied:abc", expected: DecisionDenied, ok: true},
+		{customID: askButtonPrefix + "approved:old", ok: false},
+		{customID: askButtonPrefix + "maybe:abc", ok: false},
+		{customID: "other:approved:abc", ok: false},
+	}
+
+	for _, tc := range testCases {
+		action, ok := parseAskAction(tc.customID, askButtonPrefix, "abc")
+		if ok != tc.ok || action != tc.expected {
+			t.Errorf("%s: expected (%q, %v), got (%q, %v)", tc.customID, tc.expected, tc.ok, action, ok)
+		}
+	}
+}
+
+func TestApproverSet(t *testing.T) {
+	anyone := newApproverSet(nil, nil)
+	if !anyone.allows("1", nil) {
+		t.Error("Expected empty approver set to allow everyone")
+	}
+
+	set := newApproverSet([]string{"42"}, []string{"ops"})
+	if !set.allows("42", nil) {
+		t.Error("Expected listed user to be allowed")
+	}
+	if !set.allows("7", []string{"dev", "ops"}) {
+		t.Error("Expected user with listed role to be allowed")
+	}
+	if set.allows("7", []string{"dev"}) {
+		t.Error("Expected user without listed role to be refused")
+	}
+}
+
+func TestAskResultExitCodes(t *testing.T) {
+	testCases := []struct {
+		decision string
+		code     int
+	}{
+		{decision: DecisionApproved, code: AskExitApproved},
+		{decision: DecisionDenied, code: AskExitDenied},
+		{decision: DecisionTimeout, code: AskExitTimeout},
+		{decision: DecisionInterrupted, code: ExitInterrupted},
+	}
+
+	for _, tc := range testCases {
+		if code := (AskResult{Decision: tc.decision}).exitCode(); code != tc.code {
+			t.Errorf("%s: expected exit code %d, got %d", tc.decision, tc.code, code)
+		}
+	}
+}
+
+func TestModalReason(t *testing.T) {
+	data := discordgo.ModalSubmitInteractionData{
+		Components: []discordgo.MessageComponent{
+			&discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				&discordgo.TextInput{CustomID: askReasonInput, Value: "  tests are red \n"},
+			}},
+		},
+	}
+	if reason := modalReason(data); reason != "tests are red" {
+		t.Errorf("Expected trimmed reason, got %q", reason)
+	}
+}
+
+func TestAskNonce(t *testing.T) {
+	a, err := askNonce()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b, _ := askNonce()
+	if len(a) != 16 || a == b {
+		t.Errorf("Expected distinct 16-character nonces, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Outcomes of a batch item.
+const (
+	BatchSent    = "sent"
+	BatchDropped = "dropped" // below min_level or a suppressed repeat
+	BatchFailed  = "failed"
+)
+
+// BatchItem is one message of a batch. The content comes from Content or is
+// read from File; the other fields override the config for this item only.
+type BatchItem struct {
+	Content    string            `yaml:"content" json:"content"`
+	File       string            `yaml:"file" json:"file"`
+	ChannelID  string            `yaml:"channel_id" json:"channel_id"`
+	ThreadName string            `yaml:"thread_name" json:"thread_name"`
+	Level      string            `yaml:"level" json:"level"`
+	Key        string            `yaml:"key" json:"key"`
+	Tags       []string          `yaml:"tags" json:"tags"`
+	Properties map[string]string `yaml:"properties" json:"properties"`
+
+	source string // where the item came from, for the report
+}
+
+// BatchResult is the outcome of one batch item.
+type BatchResult struct {
+	Item     int        `json:"item"`
+	Source   string     `json:"source"`
+	Status   string     `json:"status"`
+	Receipts []*Receipt `json:"receipts,omitempty"`
+	Error    string     `json:"error,omitempty"`
+
+	err error
+}
+
+// BatchReport summarises a batch. Messages counts the messages delivered
+// across all items.
+type BatchReport struct {
+	Items      int            `json:"items"`
+	Sent       int            `json:"sent"`
+	Dropped    int            `json:"dropped"`
+	Failed     int            `json:"failed"`
+	Messages   int            `json:"messages"`
+	StartedAt  time.Time      `json:"started_at"`
+	DurationMS int64          `json:"duration_ms"`
+	Results    []*BatchResult `json:"results"`
+}
+
+// load reads the item's content from its file, resolved against dir, and
+// checks that it has exactly one source of content.
+func (item *BatchItem) load(dir string) error {
+	if item.File == "" {
+		if item.Content == "" {
+			return configErrorf("%s: content or file is required", item.source)
+		}
+		return nil
+	}
+	if item.Content != "" {
+		return configErrorf("%s: set content or file, not both", item.source)
+	}
+	path := item.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configErrorf("%s: %w", item.source, err)
+	}
+	item.Content = string(data)
+	return nil
+}
+
+// globItems makes an item of every file matching the patterns.
+func globItems(patterns []string) ([]BatchItem, error) {
+	var items []BatchItem
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, configErrorf("invalid pattern %q: %w", pattern, err)
+		}
+		found := false
+		for _, path := range matches {
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				continue
+			}
+			found = true
+			item := BatchItem{File: path, source: path}
+			if err := item.load(""); err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if !found {
+			return nil, configErrorf("no files match %q", pattern)
+		}
+	}
+	return items, nil
+}
+
+// loadManifest reads a YAML list of items. Files are relative to the
+// manifest.
+func loadManifest(path string) ([]BatchItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, configErrorf("failed to read manifest: %w", err)
+	}
+	var items []BatchItem
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, configErrorf("failed to parse manifest %s: %w", path, err)
+	}
+	for i := range items {
+		items[i].source = fmt.Sprintf("%s item %d", filepath.Base(path), i+1)
+		if items[i].File != "" {
+			items[i].source = items[i].File
+		}
+		if err := items[i].load(filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// parseNDJSON reads one item per line, skipping blank lines. Files are
+// relative to the working directory.
+func parseNDJSON(r io.Reader) ([]BatchItem, error) {
+	var items []BatchItem
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		item := BatchItem{source: fmt.Sprintf("line %d", line)}
+		if err := json.Unmarshal(text, &item); err != nil {
+			return nil, configErrorf("line %d: invalid item: %w", line, err)
+		}
+		if err := item.load(""); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading items: %w", err)
+	}
+	return items, nil
+}
+
+// batchItems loads the items named by --manifest, the file patterns given
+// as arguments, or NDJSON on stdin, in that order of preference.
+func (c *CLI) batchItems() ([]BatchItem, error) {
+	var items []BatchItem
+	var err error
+	switch {
+	case c.manifest != "" && len(c.args) > 0:
+		return nil, configErrorf("usage: disgo send-batch [--manifest FILE | PATTERN... | < items.ndjson]")
+	case c.manifest != "":
+		items, err = loadManifest(c.manifest)
+	case len(c.args) > 0:
+		items, err = globItems(c.args)
+	default:
+		items, err = parseNDJSON(bytes.NewReader(c.stdinData))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errEmptyInput
+	}
+	if err := c.checkItemKeys(items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// checkItemKeys makes sure every keyed item has a key of its own. Items
+// read from a file are keyed by --key and the file; inline items must set
+// key, as their position changes when the list is reordered.
+func (c *CLI) checkItemKeys(items []BatchItem) error {
+	seen := make(map[string]string)
+	for _, item := range items {
+		key := item.Key
+		if key == "" && c.key != "" {
+			if item.File == "" {
+				return configErrorf("%s: set key on items without a file to use --key", item.source)
+			}
+			key = c.key + "@" + item.File
+		}
+		if key == "" {
+			continue
+		}
+		if other, ok := seen[key]; ok {
+			return configErrorf("%s: key %q is also used by %s", item.source, key, other)
+		}
+		seen[key] = item.source
+	}
+	return nil
+}
+
+// itemCLI returns a copy of the CLI that sends item. Config slices and maps
+// are copied so items can be prepared concurrently.
+func (c *CLI) itemCLI(item BatchItem) *CLI {
+	cfg := c.config
+	cfg.Tags = mergeTags(append([]string(nil), c.config.Tags...), item.Tags, cfg.TagMode)
+	cfg.Properties = make(map[string]string, len(c.config.Properties))
+	for k, v := range c.config.Properties {
+		cfg.Properties[k] = v
+	}
+	cfg.Properties = mergeProperties(cfg.Properties, item.Properties, cfg.PropertyMode)
+	cfg.Mentions = append([]string(nil), c.config.Mentions...)
+	if item.ChannelID != "" {
+		cfg.ChannelID = item.ChannelID
+	}
+	if item.ThreadName != "" {
+		cfg.ThreadName = item.ThreadName
+	}
+
+	ic := c.withConfig(cfg)
+	ic.stdinData = []byte(item.Content)
+	if item.Level != "" {
+		ic.level = item.Level
+	}
+	switch {
+	case item.Key != "":
+		ic.key = item.Key
+	case c.key != "":
+		// Each file keeps its own messages under the key
+		ic.key = c.key + "@" + item.File
+	}
+	return ic
+}
+
+// sendItem sends one prepared item and records the outcome in result.
+func (c *CLI) sendItem(result *BatchResult) {
+	if c.context().Err() != nil {
+		result.Status, result.err = BatchFailed, fmt.Errorf("not sent: %w", context.Cause(c.context()))
+		return
+	}
+	receipts, err := c.send()
+	result.Receipts = receipts
+	switch {
+	case err != nil:
+		result.Status, result.err = BatchFailed, err
+	case len(receipts) == 0:
+		result.Status = BatchDropped
+	default:
+		result.Status = BatchSent
+	}
+}
+
+// sharedClient lets every item use the batch's session. Items close their
+// client when done; the batch closes the session at the end.
+type sharedClient struct {
+	DiscordClient
+}
+
+func (sharedClient) Close() error { return nil }
+
+// runBatch sends the items over one session. Items for the same channel are
+// sent one after another, in order, so they share its rate limit; up to
+// --concurrency channels are sent to at once.
+func (c *CLI) runBatch(items []BatchItem) (*BatchReport, error) {
+	report := &BatchReport{Items: len(items), StartedAt: time.Now().UTC()}
+	start := time.Now()
+
+	clis := make([]*CLI, len(items))
+	lanes := make(map[string][]int)
+	var order []string
+	for i, item := range items {
+		result := &BatchResult{Item: i + 1, Source: item.source}
+		report.Results = append(report.Results, result)
+
+		ic := c.itemCLI(item)
+		if len(bytes.TrimSpace(ic.stdinData)) == 0 {
+			result.Status, result.err = BatchFailed, errEmptyInput
+			continue
+		}
+		dropped, err := ic.prepareSend()
+		if err != nil {
+			result.Status, result.err = BatchFailed, err
+			continue
+		}
+		if dropped {
+			result.Status = BatchDropped
+			continue
+		}
+		clis[i] = ic
+		lane := ic.config.ChannelID
+		if _, ok := lanes[lane]; !ok {
+			order = append(order, lane)
+		}
+		lanes[lane] = append(lanes[lane], i)
+	}
+
+	workers := c.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(order); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lane := range queue {
+				for _, i := range lane {
+					if c.config.Debug {
+						log.Printf("Sending batch item %d (%s)", i+1, items[i].source)
+					}
+					clis[i].sendItem(report.Results[i])
+				}
+			}
+		}()
+	}
+	for _, lane := range order {
+		queue <- lanes[lane]
+	}
+	close(queue)
+	wg.Wait()
+
+	var firstErr error
+	for _, r := range report.Results {
+		switch r.Status {
+		case BatchSent:
+			report.Sent++
+		case BatchDropped:
+			report.Dropped++
+		case BatchFailed:
+			report.Failed++
+			r.Error = r.err.Error()
+			if firstErr == nil {
+				firstErr = r.err
+			}
+		}
+		for _, receipt := range r.Receipts {
+			report.Messages += len(receipt.MessageIDs)
+		}
+	}
+	report.DurationMS = time.Since(start).Milliseconds()
+
+	if report.Failed > 0 {
+		err := fmt.Errorf("%d of %d items failed: %w", report.Failed, report.Items, firstErr)
+		if report.Messages > 0 {
+			return report, &partialError{err: err}
+		}
+		return report, err
+	}
+	return report, nil
+}
+
+// describeResult summarises where an item's messages went.
+func describeResult(r *BatchResult) string {
+	switch r.Status {
+	case BatchDropped:
+		return "dropped"
+	case BatchFailed:
+		return r.Error
+	}
+	var targets []string
+	messages := 0
+	for _, receipt := range r.Receipts {
+		messages += len(receipt.MessageIDs)
+		target := receipt.ChannelID
+		if receipt.ThreadID != "" {
+			target = "thread " + receipt.ThreadID
+		}
+		targets = append(targets, target)
+	}
+	return fmt.Sprintf("%d message%s to %s", messages, plural(messages), strings.Join(targets, ", "))
+}
+
+// writeBatchReport prints a line per item and a summary.
+func writeBatchReport(w io.Writer, report *BatchReport) {
+	marks := map[string]string{BatchSent: "✓", BatchDropped: "-", BatchFailed: "✗"}
+	for _, r := range report.Results {
+		fmt.Fprintf(w, "%s %s: %s\n", marks[r.Status], r.Source, describeResult(r))
+	}
+	fmt.Fprintf(w, "Sent %d of %d items (%d message%s), %d dropped, %d failed in %s\n",
+		report.Sent, report.Items, report.Messages, plural(report.Messages), report.Dropped, report.Failed,
+		(time.Duration(report.DurationMS) * time.Millisecond).String())
+}
+
+// printBatchReport prints the report as text, or as JSON with --output json.
+func (c *CLI) printBatchReport(report *BatchReport) error {
+	w := c.receiptWriter()
+	switch c.config.Output {
+	case OutputJSON:
+		data, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("error encoding report: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	case "", OutputText:
+		writeBatchReport(w, report)
+	default:
+		return configErrorf("unknown output format %q (expected text or json)", c.config.Output)
+	}
+	return nil
+}
+
+// runSendBatch handles `disgo send-batch`.
+func (c *CLI) runSendBatch() error {
+	if err := c.checkOutput(); err != nil {
+		return err
+	}
+	if err := c.checkReactTo(); err != nil {
+		return err
+	}
+	items, err := c.batchItems()
+	if err != nil {
+		return err
+	}
+
+	if c.dryRun {
+		var plans []*SendPlan
+		for _, item := range items {
+			ic := c.itemCLI(item)
+			dropped, err := ic.prepareSend()
+			if err != nil {
+				return fmt.Errorf("%s: %w", item.source, err)
+			}
+			if dropped {
+				continue
+			}
+			itemPlans, err := ic.planSend()
+			if err != nil {
+				return fmt.Errorf("%s: %w", item.source, err)
+			}
+			plans = append(plans, itemPlans...)
+		}
+		return c.printPlans(plans, true)
+	}
+
+	if c.client == nil {
+		session, err := c.newSession()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		c.client = sharedClient{session}
+	}
+
+	report, err := c.runBatch(items)
+	if printErr := c.printBatchReport(report); printErr != nil && err == nil {
+		err = printErr
+	}
+	return err
+}
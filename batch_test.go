@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"disgo/fakediscord"
+)
+
+func TestParseNDJSON(t *testing.T) {
+	input := `{"content":"one","channel_id":"200"}
+
+{"content":"two","tags":["deploy"],"properties":{"env":"prod"}}
+`
+	items, err := parseNDJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].ChannelID != "200" || items[1].source != "line 3" || items[1].Properties["env"] != "prod" {
+		t.Errorf("Expected two items with their fields, got %+v", items)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"invalid", `{"content":`, "line 1: invalid item"},
+		{"empty", `{"channel_id":"1"}`, "line 1: content or file is required"},
+		{"both", `{"content":"x","file":"a.txt"}`, "line 1: set content or file, not both"},
+		{"missing file", `{"file":"does-not-exist.txt"}`, "line 1: open does-not-exist.txt"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseNDJSON(strings.NewReader(tc.input))
+			if err == nil || !strings.Contains(err.Error(), tc.err) || exitCode(err) != ExitConfig {
+				t.Errorf("Expected a config error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestBatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("report a"), 0600)
+	os.WriteFile(filepath.Join(dir, "b.md"), []byte("report b"), 0600)
+	os.Mkdir(filepath.Join(dir, "c.md"), 0700)
+
+	items, err := globItems([]string{filepath.Join(dir, "*.md")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].Content != "report a" || items[1].source != filepath.Join(dir, "b.md") {
+		t.Errorf("Expected the two files in order, got %+v", items)
+	}
+	if _, err := globItems([]string{filepath.Join(dir, "*.txt")}); exitCode(err) != ExitConfig {
+		t.Errorf("Expected a pattern without matches to be a config error, got %v", err)
+	}
+
+	manifest := filepath.Join(dir, "batch.yaml")
+	os.WriteFile(manifest, []byte(`
+- file: a.md
+  thread_name: Report A
+- content: inline
+  level: warn
+`), 0600)
+	items, err = loadManifest(manifest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].Content != "report a" || items[0].ThreadName != "Report A" || items[1].source != "batch.yaml item 2" || items[1].Level != "warn" {
+		t.Errorf("Expected files relative to the manifest, got %+v", items)
+	}
+}
+
+func TestItemCLI(t *testing.T) {
+	cli := NewCLI()
+	cli.config.ChannelID = "100"
+	cli.config.Tags = []string{"ci"}
+	cli.config.Properties = map[string]string{"env": "staging"}
+	cli.key = "nightly"
+
+	ic := cli.itemCLI(BatchItem{Content: "x", File: "a.md", ChannelID: "200", Tags: []string{"deploy"}, Properties: map[string]string{"env": "prod"}, source: "a.md"})
+	if ic.config.ChannelID != "200" || len(ic.config.Tags) != 2 || ic.config.Properties["env"] != "prod" || ic.key != "nightly@a.md" {
+		t.Errorf("Expected the item's overrides, got %+v key %q", ic.config, ic.key)
+	}
+	if cli.config.Properties["env"] != "staging" || len(cli.config.Tags) != 1 {
+		t.Errorf("Expected the base config to be unchanged, got %+v", cli.config)
+	}
+}
+
+// runBatchJSON runs send-batch against srv and returns the JSON report.
+func runBatchJSON(t *testing.T, cli *CLI) (*BatchReport, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+
+	cli.config.Output = OutputJSON
+	batchErr := cli.runSendBatch()
+	w.Close()
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	var report BatchReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Report is not valid JSON: %v (%q)", err, buf.String())
+	}
+	return &report, batchErr
+}
+
+func TestSendBatch(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, `{"content":"one"}
+{"content":"two","channel_id":"200","thread_name":"builds"}
+{"content":"three"}
+{"content":"quiet","level":"debug"}
+{"content":"four","channel_id":"200"}
+`)
+	cli.config.MinLevel = "info"
+	cli.concurrency = 2
+	report, err := runBatchJSON(t, cli)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Items != 5 || report.Sent != 4 || report.Dropped != 1 || report.Failed != 0 || report.Messages != 4 {
+		t.Errorf("Expected 4 sent and 1 dropped, got %+v", report)
+	}
+	if got := srv.Messages("100"); len(got) != 2 || got[0].Content != "one" || got[1].Content != "three" {
+		t.Errorf("Expected channel 100 to get its items in order, got %+v", got)
+	}
+	thread := report.Results[1].Receipts[0].ThreadID
+	if thread == "" || len(srv.Messages(thread)) != 1 {
+		t.Errorf("Expected the second item in a new thread, got %+v", report.Results[1])
+	}
+	if report.Results[3].Status != BatchDropped {
+		t.Errorf("Expected the debug item to be dropped, got %+v", report.Results[3])
+	}
+}
+
+func TestSendBatchFailures(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	srv.Inject(fakediscord.Fault{Path: "channels/300/", Status: http.StatusForbidden, Code: 50013, Message: "Missing Permissions"})
+
+	cli := fakeCLI(srv, `{"content":"one"}
+{"content":"two","channel_id":"300"}
+`)
+	report, err := runBatchJSON(t, cli)
+	if exitCode(err) != ExitPartial || !strings.Contains(err.Error(), "1 of 2 items failed") {
+		t.Fatalf("Expected a partial failure, got %v", err)
+	}
+	if r := report.Results[1]; r.Status != BatchFailed || !strings.Contains(r.Error, "Missing Permissions") || len(r.Receipts) != 1 {
+		t.Errorf("Expected the failed item with its receipt, got %+v", r)
+	}
+
+	srv.Inject(fakediscord.Fault{Status: http.StatusForbidden})
+	_, err = runBatchJSON(t, fakeCLI(srv, `{"content":"one"}`))
+	if exitCode(err) != ExitPermission {
+		t.Errorf("Expected the item's exit code when nothing was sent, got %v", err)
+	}
+}
+
+func TestSendBatchKeys(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	var lines []string
+	for i := 0; i < 8; i++ {
+		lines = append(lines, fmt.Sprintf(`{"content":"v1 %d","channel_id":"%d","key":"status-%d"}`, i, 200+i, i))
+	}
+	run := func(input string) {
+		t.Helper()
+		cli := fakeCLI(srv, input)
+		cli.configPath = dir
+		cli.concurrency = 8
+		if _, err := runBatchJSON(t, cli); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	run(strings.Join(lines, "\n"))
+
+	// Reordered, the items still update their own messages
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	run(strings.ReplaceAll(strings.Join(lines, "\n"), "v1", "v2"))
+
+	for i := 0; i < 8; i++ {
+		msgs := srv.Messages(fmt.Sprint(200 + i))
+		if len(msgs) != 1 || msgs[0].Content != fmt.Sprintf("v2 %d", i) {
+			t.Errorf("Expected channel %d to have its message edited, got %+v", 200+i, msgs)
+		}
+	}
+}
+
+func TestCheckItemKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		items   []BatchItem
+		wantErr bool
+	}{
+		{"no keys", "", []BatchItem{{Content: "a"}, {Content: "b"}}, false},
+		{"files", "nightly", []BatchItem{{File: "a.md"}, {File: "b.md"}}, false},
+		{"own keys", "", []BatchItem{{Content: "a", Key: "a"}, {Content: "b", Key: "b"}}, false},
+		{"inline with --key", "nightly", []BatchItem{{Content: "a", Key: "a"}, {Content: "b"}}, true},
+		{"duplicate", "", []BatchItem{{Content: "a", Key: "a"}, {Content: "b", Key: "a"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := NewCLI()
+			cli.key = tt.key
+			err := cli.checkItemKeys(tt.items)
+			if tt.wantErr && exitCode(err) != ExitConfig {
+				t.Errorf("Expected a config error, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteBatchReport(t *testing.T) {
+	var buf bytes.Buffer
+	writeBatchReport(&buf, &BatchReport{
+		Items: 3, Sent: 1, Dropped: 1, Failed: 1, Messages: 2, DurationMS: 1500,
+		Results: []*BatchResult{
+			{Source: "a.md", Status: BatchSent, Receipts: []*Receipt{{ChannelID: "100", ThreadID: "7", MessageIDs: []string{"1", "2"}}}},
+			{Source: "line 2", Status: BatchDropped},
+			{Source: "line 3", Status: BatchFailed, Error: "boom"},
+		},
+	})
+	expected := "✓ a.md: 2 messages to thread 7\n- line 2: dropped\n✗ line 3: boom\nSent 1 of 3 items (2 messages), 1 dropped, 1 failed in 1.5s\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
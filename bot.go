@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCommandTimeout bounds how long a slash command script may run.
+const DefaultCommandTimeout = 5 * time.Minute
+
+// BotConfig is the file given to `disgo bot --commands`.
+type BotConfig struct {
+	Commands []SlashCommand `yaml:"commands"`
+	AuditLog string         `yaml:"audit_log"`
+}
+
+// SlashCommand maps a Discord slash command to a local command.
+type SlashCommand struct {
+	Name        string          `yaml:"name"`
+	Description string          `yaml:"description"`
+	Run         string          `yaml:"run"`
+	Options     []CommandOption `yaml:"options"`
+	Roles       []string        `yaml:"roles"`
+	Public      bool            `yaml:"public"`
+	Timeout     time.Duration   `yaml:"timeout"`
+}
+
+// CommandOption is a slash command argument.
+type CommandOption struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Type        string   `yaml:"type"`
+	Required    bool     `yaml:"required"`
+	Choices     []string `yaml:"choices"`
+}
+
+// AuditEntry is one line of the bot's audit log.
+type AuditEntry struct {
+	Time       time.Time         `json:"time"`
+	Command    string            `json:"command"`
+	Options    map[string]string `json:"options,omitempty"`
+	User       string            `json:"user"`
+	UserID     string            `json:"user_id"`
+	ChannelID  string            `json:"channel_id"`
+	Allowed    bool              `json:"allowed"`
+	ExitCode   int               `json:"exit_code"`
+	DurationMS int64             `json:"duration_ms"`
+	Error      string            `json:"error,omitempty"`
+}
+
+var optionTypes = map[string]discordgo.ApplicationCommandOptionType{
+	"":        discordgo.ApplicationCommandOptionString,
+	"string":  discordgo.ApplicationCommandOptionString,
+	"integer": discordgo.ApplicationCommandOptionInteger,
+	"number":  discordgo.ApplicationCommandOptionNumber,
+	"boolean": discordgo.ApplicationCommandOptionBoolean,
+	"user":    discordgo.ApplicationCommandOptionUser,
+	"channel": discordgo.ApplicationCommandOptionChannel,
+	"role":    discordgo.ApplicationCommandOptionRole,
+}
+
+// loadBotConfig reads and validates a commands file.
+func loadBotConfig(path string) (*BotConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, configErrorf("failed to read commands file: %w", err)
+	}
+	var cfg BotConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, configErrorf("failed to parse commands file: %w", err)
+	}
+	if len(cfg.Commands) == 0 {
+		return nil, configErrorf("no commands defined in %s", path)
+	}
+
+	seen := make(map[string]bool)
+	for _, cmd := range cfg.Commands {
+		if cmd.Name == "" || cmd.Run == "" {
+			return nil, configErrorf("every command needs a name and run")
+		}
+		if seen[cmd.Name] {
+			return nil, configErrorf("duplicate command %q", cmd.Name)
+		}
+		seen[cmd.Name] = true
+		for _, opt := range cmd.Options {
+			if _, ok := optionTypes[opt.Type]; !ok {
+				return nil, configErrorf("command %s: unknown option type %q", cmd.Name, opt.Type)
+			}
+		}
+		// Commands run local scripts, so nobody may run one unless the
+		// config says who
+		if len(cmd.Roles) == 0 && !cmd.Public {
+			return nil, configErrorf("command %s: set roles, or public: true to let anyone run it", cmd.Name)
+		}
+		if len(cmd.Roles) > 0 && cmd.Public {
+			return nil, configErrorf("command %s: set roles or public, not both", cmd.Name)
+		}
+	}
+	return &cfg, nil
+}
+
+// applicationCommand converts the config entry into its Discord definition.
+func (sc SlashCommand) applicationCommand() *discordgo.ApplicationCommand {
+	description := sc.Description
+	if description == "" {
+		description = "Run " + sc.Name
+	}
+	cmd := &discordgo.ApplicationCommand{
+		Name:        sc.Name,
+		Description: description,
+	}
+	for _, opt := range sc.Options {
+		optDescription := opt.Description
+		if optDescription == "" {
+			optDescription = opt.Name
+		}
+		o := &discordgo.ApplicationCommandOption{
+			Type:        optionTypes[opt.Type],
+			Name:        opt.Name,
+			Description: optDescription,
+			Required:    opt.Required,
+		}
+		for _, choice := range opt.Choices {
+			o.Choices = append(o.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+		}
+		cmd.Options = append(cmd.Options, o)
+	}
+	return cmd
+}
+
+// optionValue renders an option value the way a shell script expects it.
+func optionValue(v interface{}) string {
+	switch val := v.(type) {
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return val
+	}
+	return fmt.Sprint(v)
+}
+
+// commandInvocation builds the arguments and environment for a command run.
+// Options are the positional parameters of the run line in the order they
+// are declared, and DISGO_OPT_<NAME> variables; missing optional options are
+// empty.
+func (sc SlashCommand) commandInvocation(values map[string]string) ([]string, []string) {
+	var args, env []string
+	for _, opt := range sc.Options {
+		value := values[opt.Name]
+		args = append(args, value)
+		name := strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(opt.Name))
+		env = append(env, "DISGO_OPT_"+name+"="+value)
+	}
+	return args, env
+}
+
+// bot serves slash commands until interrupted.
+type bot struct {
+	cli      *CLI
+	ctx      context.Context
+	commands map[string]SlashCommand
+	roles    map[string]approverSet
+	auditLog string
+	auditMu  sync.Mutex
+}
+
+func (b *bot) audit(entry AuditEntry) {
+	if b.auditLog == "" {
+		return
+	}
+	b.auditMu.Lock()
+	defer b.auditMu.Unlock()
+
+	f, err := os.OpenFile(b.auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing audit log: %v\n", err)
+		return
+	}
+	defer f.Close()
+	data, _ := json.Marshal(entry)
+	f.Write(append(data, '\n'))
+}
+
+// allows reports whether a member with the given roles may run sc. Unlike
+// ask, an empty role list refuses everyone unless the command is public.
+func (b *bot) allows(sc SlashCommand, roles []string) bool {
+	if sc.Public {
+		return true
+	}
+	set := b.roles[sc.Name]
+	return !set.empty() && set.allows("", roles)
+}
+
+func (b *bot) handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	data := i.ApplicationCommandData()
+	sc, ok := b.commands[data.Name]
+	if !ok {
+		return
+	}
+
+	user, roles := interactionUser(i)
+	if user == nil {
+		return
+	}
+	values := make(map[string]string)
+	for _, opt := range data.Options {
+		values[opt.Name] = optionValue(opt.Value)
+	}
+	entry := AuditEntry{
+		Time:      time.Now().UTC(),
+		Command:   sc.Name,
+		Options:   values,
+		User:      user.Username,
+		UserID:    user.ID,
+		ChannelID: i.ChannelID,
+	}
+
+	if !b.allows(sc, roles) {
+		entry.ExitCode = -1
+		b.audit(entry)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "You are not allowed to run this command.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+	entry.Allowed = true
+
+	// Scripts usually take longer than the 3 seconds Discord waits for a
+	// response, so acknowledge first and fill in the output afterwards
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	}); err != nil {
+		entry.Error = err.Error()
+		b.audit(entry)
+		return
+	}
+
+	start := time.Now()
+	output, exitCode, err := b.run(sc, values, user, i)
+	entry.DurationMS = time.Since(start).Milliseconds()
+	entry.ExitCode = exitCode
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	b.audit(entry)
+
+	if err := b.respond(s, i.Interaction, output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error responding to /%s: %v\n", sc.Name, err)
+	}
+}
+
+// run executes the command and returns its combined output annotated with
+// the exit status.
+func (b *bot) run(sc SlashCommand, values map[string]string, user *discordgo.User, i *discordgo.InteractionCreate) (string, int, error) {
+	timeout := sc.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, timeout)
+	defer cancel()
+
+	args, env := sc.commandInvocation(values)
+	// The options are $1, $2... of the run line, unexpanded, for it to
+	// place where they belong. Appending "$@" to the line would only reach
+	// the last command of a list or pipeline.
+	cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", sc.Run, sc.Name}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env,
+		"DISGO_COMMAND="+sc.Name,
+		"DISGO_USER="+user.Username,
+		"DISGO_USER_ID="+user.ID,
+		"DISGO_CHANNEL_ID="+i.ChannelID,
+		"DISGO_GUILD_ID="+i.GuildID,
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if b.cli.config.Debug {
+		log.Printf("Running /%s for %s", sc.Name, user.Username)
+	}
+	err := cmd.Run()
+
+	output := strings.TrimRight(out.String(), "\n")
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			output += fmt.Sprintf("\n⚠️ timed out after %s", timeout)
+		} else {
+			output += fmt.Sprintf("\n⚠️ exit status %d", exitCode)
+		}
+	}
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+	return output, exitCode, err
+}
+
+// respond fills in the deferred response with the first part of the output
+// and sends the remaining parts as follow-up messages.
+func (b *bot) respond(s *discordgo.Session, interaction *discordgo.Interaction, output string) error {
+	parts := b.cli.splitMessage(output)
+	first := parts[0]
+	if _, err := s.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
+		Content:         &first,
+		AllowedMentions: noMentions(),
+	}); err != nil {
+		return fmt.Errorf("error sending response: %w", err)
+	}
+	for n, part := range parts[1:] {
+		if _, err := s.FollowupMessageCreate(interaction, true, &discordgo.WebhookParams{
+			Content:         part,
+			AllowedMentions: noMentions(),
+		}); err != nil {
+			return fmt.Errorf("error sending follow-up %d/%d: %w", n+2, len(parts), err)
+		}
+	}
+	return nil
+}
+
+// runBot registers the configured slash commands in ServerID and runs the
+// matching local command for every invocation until interrupted.
+func (c *CLI) runBot() error {
+	if c.config.Token == "" {
+		return configErrorf("discord token not configured")
+	}
+	if c.config.ServerID == "" {
+		return configErrorf("discord server ID not configured")
+	}
+	if c.commandsFile == "" {
+		return configErrorf("usage: disgo bot --commands commands.yaml")
+	}
+
+	botCfg, err := loadBotConfig(c.commandsFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discord, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	b := &bot{
+		cli:      c,
+		ctx:      ctx,
+		commands: make(map[string]SlashCommand),
+		roles:    make(map[string]approverSet),
+		auditLog: botCfg.AuditLog,
+	}
+	if b.auditLog == "" {
+		b.auditLog = filepath.Join(c.stateDir(), "bot-audit.log")
+	}
+	if err := os.MkdirAll(filepath.Dir(b.auditLog), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	var definitions []*discordgo.ApplicationCommand
+	var guildRoles []*discordgo.Role
+	for _, sc := range botCfg.Commands {
+		var roleIDs []string
+		for _, role := range sc.Roles {
+			if isSnowflake(role) {
+				roleIDs = append(roleIDs, role)
+				continue
+			}
+			if guildRoles == nil {
+				guildRoles, err = discord.GuildRoles(c.config.ServerID)
+				if err != nil {
+					return fmt.Errorf("error fetching roles: %w", err)
+				}
+			}
+			id := matchRole(guildRoles, role)
+			if id == "" {
+				return fmt.Errorf("command %s: role %q not found in server %s", sc.Name, role, c.config.ServerID)
+			}
+			roleIDs = append(roleIDs, id)
+		}
+		b.commands[sc.Name] = sc
+		b.roles[sc.Name] = newApproverSet(nil, roleIDs)
+		definitions = append(definitions, sc.applicationCommand())
+	}
+
+	discord.AddHandler(b.handle)
+	if err := c.openGateway(discord, discordgo.IntentsGuilds); err != nil {
+		return err
+	}
+
+	// A bot's user ID doubles as its application ID
+	me, err := discord.User("@me")
+	if err != nil {
+		return fmt.Errorf("error fetching bot user: %w", err)
+	}
+	if _, err := discord.ApplicationCommandBulkOverwrite(me.ID, c.config.ServerID, definitions); err != nil {
+		return fmt.Errorf("error registering commands: %w", err)
+	}
+	if c.config.Debug {
+		log.Printf("Registered %d commands in server %s", len(definitions), c.config.ServerID)
+	}
+
+	<-ctx.Done()
+	return nil
+}
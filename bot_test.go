@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const testCommandsYAML = `
+commands:
+  - name: deploy
+    description: Deploy a service
+    run: echo deploying
+    roles: ["123"]
+    options:
+      - name: service
+        type: string
+        required: true
+        choices: [api, web]
+      - name: dry-run
+        type: boolean
+`
+
+func TestLoadBotConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "disgo-bot")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "commands.yaml")
+	os.WriteFile(path, []byte(testCommandsYAML), 0644)
+
+	cfg, err := loadBotConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load commands: %v", err)
+	}
+	if len(cfg.Commands) != 1 || cfg.Commands[0].Name != "deploy" {
+		t.Fatalf("Unexpected commands %+v", cfg.Commands)
+	}
+
+	def := cfg.Commands[0].applicationCommand()
+	if len(def.Options) != 2 {
+		t.Fatalf("Expected 2 options, got %d", len(def.Options))
+	}
+	if def.Options[0].Type != discordgo.ApplicationCommandOptionString || len(def.Options[0].Choices) != 2 {
+		t.Errorf("Unexpected first option %+v", def.Options[0])
+	}
+	if def.Options[1].Type != discordgo.ApplicationCommandOptionBoolean {
+		t.Errorf("Expected boolean option, got %v", def.Options[1].Type)
+	}
+
+	os.WriteFile(path, []byte("commands:\n  - name: x\n    run: y\n    public: true\n    options:\n      - name: a\n        type: date\n"), 0644)
+	if _, err := loadBotConfig(path); exitCode(err) != ExitConfig {
+		t.Errorf("Expected a config error for unknown option type, got %v", err)
+	}
+	if _, err := loadBotConfig(filepath.Join(t.TempDir(), "missing.yaml")); exitCode(err) != ExitConfig || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a config error wrapping the missing file, got %v", err)
+	}
+
+	access := []struct {
+		yaml    string
+		wantErr bool
+	}{
+		{"roles: [\"123\"]", false},
+		{"public: true", false},
+		{"roles: []", true},
+		{"roles: [\"123\"]\n    public: true", true},
+	}
+	for _, tt := range access {
+		os.WriteFile(path, []byte("commands:\n  - name: x\n    run: y\n    "+tt.yaml+"\n"), 0644)
+		if _, err := loadBotConfig(path); (exitCode(err) == ExitConfig) != tt.wantErr {
+			t.Errorf("%s: Expected config error %v, got %v", tt.yaml, tt.wantErr, err)
+		}
+	}
+}
+
+func TestBotAllows(t *testing.T) {
+	b := &bot{roles: map[string]approverSet{
+		"deploy": newApproverSet(nil, []string{"123"}),
+		"status": newApproverSet(nil, nil),
+	}}
+	tests := []struct {
+		name  string
+		sc    SlashCommand
+		roles []string
+		want  bool
+	}{
+		{"role member", SlashCommand{Name: "deploy"}, []string{"9", "123"}, true},
+		{"other member", SlashCommand{Name: "deploy"}, []string{"9"}, false},
+		{"no roles configured", SlashCommand{Name: "status"}, []string{"9"}, false},
+		{"public", SlashCommand{Name: "status", Public: true}, nil, true},
+	}
+	for _, tt := range tests {
+		if got := b.allows(tt.sc, tt.roles); got != tt.want {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCommandInvocation(t *testing.T) {
+	sc := SlashCommand{
+		Name: "deploy",
+		Options: []CommandOption{
+			{Name: "service"},
+			{Name: "dry-run"},
+		},
+	}
+	args, env := sc.commandInvocation(map[string]string{"service": "api"})
+	if len(args) != 2 || args[0] != "api" || args[1] != "" {
+		t.Errorf("Unexpected args %q", args)
+	}
+	if env[0] != "DISGO_OPT_SERVICE=api" || env[1] != "DISGO_OPT_DRY_RUN=" {
+		t.Errorf("Unexpected env %q", env)
+	}
+
+	if v := optionValue(float64(3)); v != "3" {
+		t.Errorf("Expected integral number without decimals, got %q", v)
+	}
+	if v := optionValue(true); v != "true" {
+		t.Errorf("Expected 'true', got %q", v)
+	}
+}
+
+func TestBotRunCommand(t *testing.T) {
+	b := &bot{cli: NewCLI(), ctx: context.Background()}
+	sc := SlashCommand{
+		Name:    "greet",
+		Run:     `printf 'hello %s from %s' "$1" "$DISGO_USER"`,
+		Options: []CommandOption{{Name: "name"}},
+	}
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{ChannelID: "1"}}
+	user := &discordgo.User{ID: "42", Username: "alice"}
+
+	// Options are passed as arguments without shell expansion
+	output, code, err := b.run(sc, map[string]string{"name": "$USER; rm -rf /"}, user, i)
+	if err != nil || code != 0 {
+		t.Fatalf("Command failed: %v (exit %d)", err, code)
+	}
+	if output != "hello $USER; rm -rf / from alice" {
+		t.Errorf("Unexpected output %q", output)
+	}
+
+	// Options reach whichever command of a pipeline uses them
+	sc.Run = `echo "$1" | tr a-z A-Z; echo "$DISGO_OPT_NAME" | cat`
+	output, _, err = b.run(sc, map[string]string{"name": "api"}, user, i)
+	if err != nil || output != "API\napi" {
+		t.Errorf("Expected the option in both commands, got %q (%v)", output, err)
+	}
+
+	sc.Run = "echo oops; exit 3"
+	output, code, _ = b.run(sc, nil, user, i)
+	if code != 3 || !strings.Contains(output, "exit status 3") {
+		t.Errorf("Expected exit status 3 in output, got %q (exit %d)", output, code)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CodeAuto asks for the code block language to be guessed.
+const CodeAuto = "auto"
+
+// codePlain is the value of a bare --code: a fence without a language.
+const codePlain = "plain"
+
+// codeFlag is --code with an optional value: a bare --code wraps the content
+// without a language, --code=LANG sets one and --code=auto guesses it.
+type codeFlag struct {
+	value *string
+}
+
+func (f codeFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f codeFlag) Set(s string) error {
+	if s == "true" {
+		s = codePlain
+	}
+	*f.value = s
+	return nil
+}
+
+func (f codeFlag) IsBoolFlag() bool { return true }
+
+// extensionLanguages maps file extensions to code block languages.
+var extensionLanguages = map[string]string{
+	".diff":  "diff",
+	".patch": "diff",
+	".json":  "json",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".go":    "go",
+	".py":    "python",
+	".log":   "log",
+	".sh":    "bash",
+	".js":    "js",
+	".ts":    "ts",
+	".sql":   "sql",
+	".toml":  "toml",
+	".xml":   "xml",
+}
+
+var (
+	goPattern      = regexp.MustCompile(`(?m)^(package \w+|func (\(\w+ \*?\w+\) )?\w+\(|import \()`)
+	pythonPattern  = regexp.MustCompile(`(?m)^(Traceback \(most recent call last\)|def \w+\(.*\):|class \w+.*:|from [\w.]+ import |import \w+$)`)
+	yamlPattern    = regexp.MustCompile(`^(\s*- )?[\w.-]+:( .*)?$`)
+	logLinePattern = regexp.MustCompile(`^(\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}|\[?(DEBUG|INFO|WARN|WARNING|ERROR|FATAL|CRITICAL)\]?[\s:])`)
+)
+
+// detectLanguage guesses a code block language from the filename, if given,
+// and otherwise from the content. It returns "" when nothing fits.
+func detectLanguage(content, filename string) string {
+	if lang, ok := extensionLanguages[strings.ToLower(filepath.Ext(filename))]; ok {
+		return lang
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return ""
+	}
+	if strings.HasPrefix(trimmed, "diff --git ") ||
+		(strings.Contains(trimmed, "\n+++ ") && strings.Contains(trimmed, "\n@@ ")) ||
+		strings.HasPrefix(trimmed, "--- ") && strings.Contains(trimmed, "\n+++ ") {
+		return "diff"
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	if goPattern.MatchString(trimmed) {
+		return "go"
+	}
+	if pythonPattern.MatchString(trimmed) {
+		return "python"
+	}
+
+	lines := strings.Split(trimmed, "\n")
+	var yamlLines, logLines, total int
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		total++
+		if logLinePattern.MatchString(line) {
+			logLines++
+		}
+		if yamlPattern.MatchString(line) || strings.HasPrefix(line, "  ") || line == "---" {
+			yamlLines++
+		}
+	}
+	// Most lines have to agree before calling it logs or YAML
+	switch {
+	case logLines*2 > total:
+		return "log"
+	case yamlLines == total && strings.Contains(trimmed, ":"):
+		return "yaml"
+	}
+	return ""
+}
+
+// wrapCode places content in a fenced code block, escaping any fences inside
+// so the content can't end the block early.
+func wrapCode(content, lang string) string {
+	body := strings.TrimRight(escapeFences(content), "\n")
+	return fenceMarker + lang + "\n" + body + "\n" + fenceMarker
+}
+
+// codeLanguage resolves the --code setting for content.
+func (c *CLI) codeLanguage(content string) string {
+	switch c.config.Code {
+	case codePlain:
+		return ""
+	case CodeAuto:
+		return detectLanguage(content, c.filename)
+	}
+	return c.config.Code
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		filename string
+		expected string
+	}{
+		{"filename wins", `{"a": 1}`, "config.yml", "yaml"},
+		{"patch file", "anything", "fix.patch", "diff"},
+		{"git diff", "diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b", "", "diff"},
+		{"unified diff", "--- a/x\n+++ b/x\n@@ -1 +1 @@", "", "diff"},
+		{"json object", `{"name": "disgo", "tags": ["a"]}`, "", "json"},
+		{"json array", "[1, 2, 3]", "", "json"},
+		{"invalid json", "{not json}", "", ""},
+		{"go", "package main\n\nfunc main() {\n}", "", "go"},
+		{"python traceback", "Traceback (most recent call last):\n  File \"x.py\", line 1", "", "python"},
+		{"python def", "import os\n\ndef main():\n    pass", "", "python"},
+		{"yaml", "name: disgo\ntags:\n  - a\n  - b", "", "yaml"},
+		{"logs", "2026-10-18 09:00:01 INFO starting\n2026-10-18 09:00:02 ERROR failed\nstack line", "", "log"},
+		{"level logs", "[INFO] ready\n[WARN] slow\n", "", "log"},
+		{"prose", "The deploy finished.\nAll good here", "", ""},
+		{"empty", "  \n", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detectLanguage(tc.content, tc.filename); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWrapCode(t *testing.T) {
+	got := wrapCode("x := 1\n```\nescape\n", "go")
+	if !strings.HasPrefix(got, "```go\nx := 1\n") || !strings.HasSuffix(got, "escape\n```") {
+		t.Errorf("Expected a go code block, got %q", got)
+	}
+	if strings.Count(got, "```") != 2 {
+		t.Errorf("Expected inner fences to be escaped, got %q", got)
+	}
+}
+
+func TestCodeFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"bare", []string{"--code"}, codePlain},
+		{"language", []string{"--code=python"}, "python"},
+		{"auto", []string{"--code=auto", "--filename", "out.json"}, CodeAuto},
+		{"unset", []string{}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := NewCLI()
+			if err := cli.parseFlags(tc.args); err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+			if cli.code != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, cli.code)
+			}
+		})
+	}
+}
+
+func TestPrepareContentCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		ansi     string
+		filename string
+		input    string
+		expected string
+	}{
+		{"plain", codePlain, "", "", "hello\n", "```\nhello\n```"},
+		{"language", "sh", "", "", "ls", "```sh\nls\n```"},
+		{"auto from filename", CodeAuto, "", "main.go", "x", "```go\nx\n```"},
+		{"strip then wrap", "plain", ANSIStrip, "", "\x1b[31mred\x1b[0m", "```\nred\n```"},
+		{"ansi block not wrapped twice", "go", ANSIColor, "", "\x1b[31mred", "```ansi\n\x1b[0;31mred\x1b[0m\n```"},
+		{"no code", "", "", "", "text", "text"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := NewCLI()
+			cli.config.Code = tc.code
+			cli.config.ANSI = tc.ansi
+			cli.filename = tc.filename
+			cli.stdinData = []byte(tc.input)
+			if err := cli.prepareContent(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := string(cli.stdinData); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCodeBlockSplitBudget(t *testing.T) {
+	cli := NewCLI()
+	cli.config.MaxMessageSize = 100
+	cli.config.MessageMode = ModeSerialize
+	cli.config.Code = "python"
+	cli.stdinData = []byte(strings.Repeat("print('hello world')\n", 20))
+	if err := cli.prepareContent(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	parts := cli.splitMessage(string(cli.stdinData))
+	if len(parts) < 2 {
+		t.Fatalf("Expected several parts, got %d", len(parts))
+	}
+	for i, part := range parts {
+		if len(part) > 100 {
+			t.Errorf("Part %d exceeds budget: %d", i, len(part))
+		}
+		if !strings.HasPrefix(part, "```python\n") || !strings.HasSuffix(part, "```") {
+			t.Errorf("Part %d should be its own python block: %q", i, part)
+		}
+	}
+}
+
+func TestSendRejectsArguments(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--code", "go"}, "use --code=go"},
+		{[]string{"sned"}, "unknown command or unexpected argument \"sned\""},
+	}
+
+	for _, tt := range tests {
+		cli := NewCLI()
+		if err := cli.parseFlags(tt.args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		cli.stdinData = []byte("package main")
+		err := cli.runSend()
+		if exitCode(err) != ExitConfig || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: Expected a config error mentioning %q, got %v", tt.args, tt.want, err)
+		}
+	}
+}
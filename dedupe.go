@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultDedupeWindow is how long repeats are suppressed when no window is
+// configured.
+const DefaultDedupeWindow = 10 * time.Minute
+
+// maxSampleLength bounds the content kept in the state file for summaries.
+const maxSampleLength = 120
+
+// DedupeEntry tracks a message that is currently being suppressed.
+type DedupeEntry struct {
+	ChannelID   string    `json:"channel_id"`
+	ContentHash string    `json:"content_hash"`
+	Sample      string    `json:"sample"`
+	WindowStart time.Time `json:"window_start"`
+	Repeats     int       `json:"repeats"`
+}
+
+// expired reports whether the suppression window has closed.
+func (e DedupeEntry) expired(window time.Duration, now time.Time) bool {
+	return !now.Before(e.WindowStart.Add(window))
+}
+
+// summary describes the suppressed repeats.
+func (e DedupeEntry) summary() string {
+	return fmt.Sprintf("🔁 Repeated %d more time%s since %s:\n> %s",
+		e.Repeats, plural(e.Repeats), e.WindowStart.Local().Format("15:04"), e.Sample)
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// contentSample returns the first line of content, shortened for summaries.
+func contentSample(content string) string {
+	line := strings.TrimSpace(content)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	if runes := []rune(line); len(runes) > maxSampleLength {
+		line = string(runes[:maxSampleLength]) + "…"
+	}
+	return line
+}
+
+func (c *CLI) dedupeStatePath() string {
+	return filepath.Join(c.stateDir(), "dedupe.json")
+}
+
+func (c *CLI) dedupeEnabled() bool {
+	return c.config.Dedupe || c.dedupeKey != ""
+}
+
+func (c *CLI) getEffectiveDedupeWindow() time.Duration {
+	if c.config.DedupeWindow <= 0 {
+		return DefaultDedupeWindow
+	}
+	return c.config.DedupeWindow
+}
+
+// dedupeID identifies the message: the --dedupe-key if given, otherwise a
+// hash of the content. Both are scoped to the target channel.
+func (c *CLI) dedupeID(hash string) string {
+	if c.dedupeKey != "" {
+		return c.config.ChannelID + "/key/" + c.dedupeKey
+	}
+	return c.config.ChannelID + "/hash/" + hash
+}
+
+// pendingSummary is a repeat summary that needs to be posted.
+type pendingSummary struct {
+	channelID string
+	text      string
+}
+
+// checkDedupe records this invocation in the dedupe state and reports
+// whether the message should be suppressed. It also returns summaries for
+// windows that have closed or whose content changed: under a --dedupe-key
+// when the key's content differs, and otherwise when different content is
+// sent to the same channel.
+func (c *CLI) checkDedupe(now time.Time) (bool, []pendingSummary, error) {
+	unlock, err := lockState(c.dedupeStatePath())
+	if err != nil {
+		return false, nil, err
+	}
+	defer unlock()
+
+	entries := make(map[string]DedupeEntry)
+	if err := loadState(c.dedupeStatePath(), &entries); err != nil {
+		return false, nil, err
+	}
+
+	content := string(c.stdinData)
+	hash := contentHash(content)
+	id := c.dedupeID(hash)
+	window := c.getEffectiveDedupeWindow()
+
+	hashPrefix := c.config.ChannelID + "/hash/"
+	var summaries []pendingSummary
+	for key, e := range entries {
+		var changed bool
+		if c.dedupeKey != "" {
+			changed = key == id && e.ContentHash != hash
+		} else {
+			changed = key != id && strings.HasPrefix(key, hashPrefix)
+		}
+		if !e.expired(window, now) && !changed {
+			continue
+		}
+		if e.Repeats > 0 {
+			summaries = append(summaries, pendingSummary{channelID: e.ChannelID, text: e.summary()})
+		}
+		delete(entries, key)
+	}
+
+	e, suppressed := entries[id]
+	if suppressed {
+		e.Repeats++
+	} else {
+		e = DedupeEntry{
+			ChannelID:   c.config.ChannelID,
+			ContentHash: hash,
+			Sample:      contentSample(content),
+			WindowStart: now,
+		}
+	}
+	entries[id] = e
+
+	if err := saveState(c.dedupeStatePath(), entries); err != nil {
+		return false, nil, err
+	}
+	return suppressed, summaries, nil
+}
+
+// forgetDedupe drops the entry for this message after a failed send, so the
+// next attempt is not suppressed.
+func (c *CLI) forgetDedupe() error {
+	unlock, err := lockState(c.dedupeStatePath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries := make(map[string]DedupeEntry)
+	if err := loadState(c.dedupeStatePath(), &entries); err != nil {
+		return err
+	}
+	delete(entries, c.dedupeID(contentHash(string(c.stdinData))))
+	return saveState(c.dedupeStatePath(), entries)
+}
+
+// sendSummaries posts repeat summaries without pinging anyone.
+func (c *CLI) sendSummaries(summaries []pendingSummary) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	for _, s := range summaries {
+		_, err := discord.ChannelMessageSendComplex(s.channelID, &discordgo.MessageSend{
+			Content:         s.text,
+			AllowedMentions: noMentions(),
+		})
+		if err != nil {
+			return fmt.Errorf("error sending repeat summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// suppressRepeat applies flood suppression before a send. It returns true
+// when the message is a repeat inside the window and must not be sent.
+func (c *CLI) suppressRepeat() (bool, error) {
+	if !c.dedupeEnabled() || len(c.stdinData) == 0 {
+		return false, nil
+	}
+
+	suppressed, summaries, err := c.checkDedupe(time.Now())
+	if err != nil {
+		return false, err
+	}
+	if err := c.sendSummaries(summaries); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if suppressed && c.config.Debug {
+		log.Printf("Suppressed repeated message within %s window", c.getEffectiveDedupeWindow())
+	}
+	return suppressed, nil
+}
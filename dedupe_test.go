@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDedupeCLI(t *testing.T) *CLI {
+	tmpDir, err := os.MkdirTemp("", "disgo-dedupe")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	cli := NewCLI()
+	cli.configPath = tmpDir
+	cli.config.ChannelID = "chan"
+	cli.config.Dedupe = true
+	cli.config.DedupeWindow = 10 * time.Minute
+	return cli
+}
+
+func TestDedupeSuppressesRepeats(t *testing.T) {
+	cli := newDedupeCLI(t)
+	cli.stdinData = []byte("disk full on db-1\nmore detail")
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	suppressed, summaries, err := cli.checkDedupe(start)
+	if err != nil || suppressed || len(summaries) != 0 {
+		t.Fatalf("First message should be sent: suppressed=%v summaries=%v err=%v", suppressed, summaries, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		suppressed, _, err = cli.checkDedupe(start.Add(time.Duration(i) * time.Minute))
+		if err != nil || !suppressed {
+			t.Fatalf("Repeat %d should be suppressed: suppressed=%v err=%v", i, suppressed, err)
+		}
+	}
+
+	// Once the window closes the next message is sent with a summary
+	suppressed, summaries, err = cli.checkDedupe(start.Add(11 * time.Minute))
+	if err != nil || suppressed {
+		t.Fatalf("Message after window should be sent: suppressed=%v err=%v", suppressed, err)
+	}
+	if len(summaries) != 1 || !strings.Contains(summaries[0].text, "Repeated 3 more times") {
+		t.Fatalf("Expected a summary of 3 repeats, got %v", summaries)
+	}
+	if !strings.Contains(summaries[0].text, "disk full on db-1") || strings.Contains(summaries[0].text, "more detail") {
+		t.Errorf("Expected the first line as sample, got %q", summaries[0].text)
+	}
+}
+
+func TestDedupeKeyContentChange(t *testing.T) {
+	cli := newDedupeCLI(t)
+	cli.dedupeKey = "backup"
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	cli.stdinData = []byte("backup failed")
+	cli.checkDedupe(now)
+	suppressed, _, _ := cli.checkDedupe(now.Add(time.Minute))
+	if !suppressed {
+		t.Fatal("Expected repeat to be suppressed")
+	}
+
+	cli.stdinData = []byte("backup succeeded")
+	suppressed, summaries, err := cli.checkDedupe(now.Add(2 * time.Minute))
+	if err != nil || suppressed {
+		t.Fatalf("Changed content should be sent: suppressed=%v err=%v", suppressed, err)
+	}
+	if len(summaries) != 1 || !strings.Contains(summaries[0].text, "backup failed") {
+		t.Errorf("Expected summary for the previous content, got %v", summaries)
+	}
+}
+
+func TestDedupeHashContentChange(t *testing.T) {
+	cli := newDedupeCLI(t)
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	cli.stdinData = []byte("disk full on db-1")
+	cli.checkDedupe(now)
+	if suppressed, _, _ := cli.checkDedupe(now.Add(time.Minute)); !suppressed {
+		t.Fatal("Expected repeat to be suppressed")
+	}
+
+	other := newDedupeCLI(t)
+	other.configPath = cli.configPath
+	other.config.ChannelID = "elsewhere"
+	other.stdinData = []byte("unrelated")
+	if _, summaries, _ := other.checkDedupe(now.Add(2 * time.Minute)); len(summaries) != 0 {
+		t.Errorf("Expected content in another channel to leave the window open, got %v", summaries)
+	}
+
+	cli.stdinData = []byte("disk ok on db-1")
+	suppressed, summaries, err := cli.checkDedupe(now.Add(3 * time.Minute))
+	if err != nil || suppressed {
+		t.Fatalf("Changed content should be sent: suppressed=%v err=%v", suppressed, err)
+	}
+	if len(summaries) != 1 || !strings.Contains(summaries[0].text, "Repeated 1 more time since") || !strings.Contains(summaries[0].text, "disk full on db-1") {
+		t.Errorf("Expected a summary for the previous content, got %v", summaries)
+	}
+
+	cli.stdinData = []byte("disk full on db-1")
+	if suppressed, _, _ := cli.checkDedupe(now.Add(4 * time.Minute)); suppressed {
+		t.Error("Expected the old content to start a new window after the change")
+	}
+}
+
+func TestLockStateExclusive(t *testing.T) {
+	cli := newDedupeCLI(t)
+	path := cli.dedupeStatePath()
+
+	unlock, err := lockState(path)
+	if err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlockSecond, err := lockState(path)
+		if err == nil {
+			unlockSecond()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Second lock acquired while the first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Second lock not acquired after release")
+	}
+}
+
+func TestLockStateBreaksStaleLock(t *testing.T) {
+	cli := newDedupeCLI(t)
+	path := cli.dedupeStatePath()
+	if err := os.MkdirAll(cli.stateDir(), 0755); err != nil {
+		t.Fatalf("Failed to create state directory: %v", err)
+	}
+
+	// A lock left behind by a process killed a moment ago
+	lockPath := path + ".lock"
+	if err := os.WriteFile(lockPath, []byte("99999\n"), 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	left := time.Now().Add(-lockStaleAfter + 500*time.Millisecond)
+	if err := os.Chtimes(lockPath, left, left); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+
+	start := time.Now()
+	unlock, err := lockState(path)
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be broken, got %v", err)
+	}
+	unlock()
+	if elapsed := time.Since(start); elapsed >= lockTimeout {
+		t.Errorf("Expected the lock within %s, took %s", lockTimeout, elapsed)
+	}
+}
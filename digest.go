@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDigestSamples is how many distinct messages are shown per tag.
+const maxDigestSamples = 5
+
+// untaggedGroup is the heading for digest entries without tags.
+const untaggedGroup = "untagged"
+
+// DigestEntry is a message waiting to be included in the next digest.
+type DigestEntry struct {
+	Time       time.Time         `json:"time"`
+	Content    string            `json:"content"`
+	Level      string            `json:"level,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// entrySeverity returns the entry's --level, falling back to a level or
+// severity property, or a tag naming a level. Anything else counts as info.
+func entrySeverity(e DigestEntry) string {
+	candidates := []string{e.Level, e.Properties["level"], e.Properties["severity"]}
+	candidates = append(candidates, e.Tags...)
+	for _, c := range candidates {
+		if level, err := parseLevel(c); err == nil {
+			return level
+		}
+	}
+	return LevelInfo
+}
+
+// digestSample is a distinct message and how often it occurred.
+type digestSample struct {
+	sample string
+	count  int
+	first  time.Time
+}
+
+// renderDigest builds the grouped summary: counts by severity, then for each
+// tag the distinct messages with their repeat counts.
+func renderDigest(entries []DigestEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	severities := make(map[string]int)
+	groups := make(map[string]map[string]*digestSample)
+	groupCounts := make(map[string]int)
+	for _, e := range entries {
+		severities[entrySeverity(e)]++
+
+		tags := e.Tags
+		if len(tags) == 0 {
+			tags = []string{untaggedGroup}
+		}
+		hash := contentHash(e.Content)
+		for _, tag := range tags {
+			if groups[tag] == nil {
+				groups[tag] = make(map[string]*digestSample)
+			}
+			groupCounts[tag]++
+			s := groups[tag][hash]
+			if s == nil {
+				s = &digestSample{sample: contentSample(e.Content), first: e.Time}
+				groups[tag][hash] = s
+			}
+			s.count++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 **Digest**: %d message%s since %s\n",
+		len(entries), plural(len(entries)), entries[0].Time.Local().Format("2006-01-02 15:04"))
+
+	var counts []string
+	// Most severe first
+	for i := len(levelOrder) - 1; i >= 0; i-- {
+		sev := levelOrder[i]
+		if n := severities[sev]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, sev))
+		}
+	}
+	fmt.Fprintf(&b, "%s\n", strings.Join(counts, " · "))
+
+	tags := make([]string, 0, len(groups))
+	for tag := range groups {
+		tags = append(tags, tag)
+	}
+	// Busiest groups first, then alphabetical for a stable layout
+	sort.Slice(tags, func(i, j int) bool {
+		if groupCounts[tags[i]] != groupCounts[tags[j]] {
+			return groupCounts[tags[i]] > groupCounts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+
+	for _, tag := range tags {
+		samples := make([]*digestSample, 0, len(groups[tag]))
+		for _, s := range groups[tag] {
+			samples = append(samples, s)
+		}
+		sort.Slice(samples, func(i, j int) bool {
+			if samples[i].count != samples[j].count {
+				return samples[i].count > samples[j].count
+			}
+			return samples[i].first.Before(samples[j].first)
+		})
+
+		fmt.Fprintf(&b, "\n**%s** (%d)\n", tag, groupCounts[tag])
+		for i, s := range samples {
+			if i == maxDigestSamples {
+				fmt.Fprintf(&b, "• …and %d more distinct message%s\n", len(samples)-i, plural(len(samples)-i))
+				break
+			}
+			fmt.Fprintf(&b, "• %d× %s\n", s.count, s.sample)
+		}
+	}
+	return b.String()
+}
+
+// digestStatePath is per config profile so each profile gets its own digest.
+func (c *CLI) digestStatePath() string {
+	return filepath.Join(c.stateDir(), "digest-"+c.configName+".json")
+}
+
+// addToDigest appends stdin to the digest store.
+func (c *CLI) addToDigest() error {
+	// Summaries are plain text, so terminal colors are always removed
+	text, _ := renderTerminal(string(c.stdinData), false)
+	content := strings.TrimSpace(text)
+	if content == "" {
+		return nil // Nothing to add
+	}
+	below, err := c.belowMinLevel()
+	if err != nil || below {
+		return err
+	}
+
+	unlock, err := lockState(c.digestStatePath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var entries []DigestEntry
+	if err := loadState(c.digestStatePath(), &entries); err != nil {
+		return err
+	}
+	var level string
+	if c.level != "" {
+		if level, err = parseLevel(c.level); err != nil {
+			return err
+		}
+	}
+	entries = append(entries, DigestEntry{
+		Time:       time.Now().UTC(),
+		Content:    content,
+		Level:      level,
+		Tags:       c.config.Tags,
+		Properties: c.config.Properties,
+	})
+	return saveState(c.digestStatePath(), entries)
+}
+
+// sendDigest posts the pending entries as one summary and removes them from
+// the store. Entries added while the summary is being sent are kept for the
+// next digest.
+func (c *CLI) sendDigest() error {
+	unlock, err := lockState(c.digestStatePath())
+	if err != nil {
+		return err
+	}
+	var entries []DigestEntry
+	err = loadState(c.digestStatePath(), &entries)
+	unlock()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if c.config.Debug {
+			log.Printf("Digest is empty, nothing to send")
+		}
+		return nil
+	}
+
+	summary := renderDigest(entries)
+	sender := c.withConfig(c.config)
+	sender.stdinData = []byte(summary)
+	// Route tags apply to the individual entries, not the digest itself
+	sender.config.Routes = nil
+	if sender.config.MessageMode != ModeTruncate && len(sender.splitMessage(summary)) > 1 && sender.config.ThreadName == "" {
+		sender.config.ThreadName = "Digest " + time.Now().Format("2006-01-02 15:04")
+	}
+
+	receipt, err := sender.sendToDiscord()
+	if err != nil {
+		return err
+	}
+
+	unlock, err = lockState(c.digestStatePath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var current []DigestEntry
+	if err := loadState(c.digestStatePath(), &current); err != nil {
+		return err
+	}
+	if len(current) >= len(entries) {
+		current = current[len(entries):]
+	} else {
+		current = nil
+	}
+	if err := saveState(c.digestStatePath(), current); err != nil {
+		return err
+	}
+
+	if receipt != nil {
+		return c.printReceipts([]*Receipt{receipt})
+	}
+	return nil
+}
+
+// runDigest handles `disgo digest add` and `disgo digest send [--every D]`.
+func (c *CLI) runDigest() error {
+	if len(c.args) != 1 {
+		return configErrorf("usage: disgo digest add|send")
+	}
+
+	switch c.args[0] {
+	case "add":
+		return c.addToDigest()
+	case "send":
+		if err := c.checkCredentials(); err != nil {
+			return err
+		}
+		if c.every <= 0 {
+			return c.sendDigest()
+		}
+
+		// Runs until interrupted or --timeout runs out
+		ctx := c.context()
+		ticker := time.NewTicker(c.every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := c.sendDigest(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error sending digest: %v\n", err)
+				}
+			}
+		}
+	}
+	return configErrorf("unknown digest action %q (expected add or send)", c.args[0])
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntrySeverity(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    DigestEntry
+		expected string
+	}{
+		{"level field", DigestEntry{Level: "critical", Tags: []string{"error"}}, "critical"},
+		{"level property", DigestEntry{Properties: map[string]string{"level": "ERROR"}}, "error"},
+		{"severity property", DigestEntry{Properties: map[string]string{"severity": "fatal"}}, "critical"},
+		{"tag", DigestEntry{Tags: []string{"backup", "warning"}}, "warn"},
+		{"property wins over tag", DigestEntry{Tags: []string{"debug"}, Properties: map[string]string{"level": "error"}}, "error"},
+		{"default", DigestEntry{Tags: []string{"backup"}}, "info"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := entrySeverity(tc.entry); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	entries := []DigestEntry{
+		{Time: start, Content: "disk full on db-1", Tags: []string{"db", "error"}},
+		{Time: start.Add(time.Minute), Content: "disk full on db-1", Tags: []string{"db", "error"}},
+		{Time: start.Add(2 * time.Minute), Content: "backup done\ndetails", Tags: []string{"backup"}},
+		{Time: start.Add(3 * time.Minute), Content: "hello"},
+	}
+
+	out := renderDigest(entries)
+	for _, want := range []string{
+		"4 messages since",
+		"2 error · 2 info",
+		"**db** (2)\n• 2× disk full on db-1",
+		"**backup** (1)\n• 1× backup done\n",
+		"**untagged** (1)\n• 1× hello",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected digest to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "details") {
+		t.Errorf("Expected only the first line of each message, got:\n%s", out)
+	}
+	if strings.Index(out, "**db**") > strings.Index(out, "**backup**") {
+		t.Errorf("Expected busiest groups first, got:\n%s", out)
+	}
+
+	if renderDigest(nil) != "" {
+		t.Error("Expected an empty digest to render nothing")
+	}
+}
+
+func TestRenderDigestLimitsSamples(t *testing.T) {
+	var entries []DigestEntry
+	for i := 0; i < maxDigestSamples+2; i++ {
+		entries = append(entries, DigestEntry{Content: strings.Repeat("x", i+1), Tags: []string{"noise"}})
+	}
+
+	out := renderDigest(entries)
+	if !strings.Contains(out, "…and 2 more distinct messages") {
+		t.Errorf("Expected remaining samples to be counted, got:\n%s", out)
+	}
+}
+
+func TestAddToDigest(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "disgo-digest")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cli := NewCLI()
+	cli.configPath = tmpDir
+	cli.config.Tags = []string{"db"}
+	cli.config.Properties = map[string]string{"host": "db-1"}
+
+	for _, content := range []string{"first\n", "  ", "second"} {
+		cli.stdinData = []byte(content)
+		if err := cli.addToDigest(); err != nil {
+			t.Fatalf("Failed to add to digest: %v", err)
+		}
+	}
+
+	var entries []DigestEntry
+	if err := loadState(cli.digestStatePath(), &entries); err != nil {
+		t.Fatalf("Failed to load digest: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries (blank input skipped), got %d", len(entries))
+	}
+	if entries[0].Content != "first" || entries[1].Content != "second" {
+		t.Errorf("Expected entries in order, got %q and %q", entries[0].Content, entries[1].Content)
+	}
+	if entries[0].Tags[0] != "db" || entries[0].Properties["host"] != "db-1" {
+		t.Errorf("Expected tags and properties to be stored, got %+v", entries[0])
+	}
+
+	// Each config profile keeps its own digest
+	other := NewCLI()
+	other.configPath = tmpDir
+	other.configName = "other"
+	if other.digestStatePath() == cli.digestStatePath() {
+		t.Error("Expected a separate digest store per config profile")
+	}
+}
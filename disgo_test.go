@@ -474,4 +474,39 @@ func TestMessageModeHandling(t *testing.T) {
 					}
 			})
 	}
-}
\ No newline at end of file
+}
+
+func TestCommandCheckFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		wantErr bool
+	}{
+		{"send", []string{"--dry-run"}, false},
+		{"send", []string{"--in", "2h"}, false},
+		{"send-batch", []string{"--dry-run"}, false},
+		{"poll", []string{"Lunch?", "--dry-run"}, false},
+		{"delete", []string{"123", "--dry-run"}, true},
+		{"edit", []string{"123", "--dry-run"}, true},
+		{"react", []string{"123", "👍", "--dry-run"}, true},
+		{"digest", []string{"send", "--dry-run"}, true},
+		{"send-batch", []string{"--in", "2h"}, true},
+		{"edit", []string{"123", "--at", "09:00"}, true},
+		{"poll", []string{"Lunch?", "--cron", "@daily"}, true},
+		{"delete", []string{"123"}, false},
+	}
+
+	for _, tt := range tests {
+		cli := NewCLI()
+		if err := cli.parseFlags(tt.args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		err := commands[tt.command].checkFlags(tt.command, cli)
+		if tt.wantErr && exitCode(err) != ExitConfig {
+			t.Errorf("%s %v: Expected a config error, got %v", tt.command, tt.args, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s %v: Unexpected error: %v", tt.command, tt.args, err)
+		}
+	}
+}
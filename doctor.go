@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Outcomes of a doctor check.
+const (
+	CheckOK      = "ok"
+	CheckWarn    = "warn"
+	CheckFail    = "fail"
+	CheckSkipped = "skipped"
+)
+
+// CheckResult is the outcome of one doctor check, with a suggested fix when
+// it didn't pass.
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+	code   int
+}
+
+// permissionCheck is a channel permission and what disgo needs it for.
+type permissionCheck struct {
+	name string
+	bit  int64
+	use  string
+}
+
+// requiredPermissions are the channel permissions sends rely on.
+var requiredPermissions = []permissionCheck{
+	{"Send Messages", discordgo.PermissionSendMessages, "posting messages"},
+	{"Create Public Threads", discordgo.PermissionCreatePublicThreads, "--thread"},
+	{"Attach Files", discordgo.PermissionAttachFiles, "file attachments"},
+	{"Embed Links", discordgo.PermissionEmbedLinks, "level embeds, --format fields and images"},
+}
+
+// channelTypeNames names the channel types for people.
+var channelTypeNames = map[discordgo.ChannelType]string{
+	discordgo.ChannelTypeGuildText:          "text channel",
+	discordgo.ChannelTypeDM:                 "direct message",
+	discordgo.ChannelTypeGuildVoice:         "voice channel",
+	discordgo.ChannelTypeGroupDM:            "group direct message",
+	discordgo.ChannelTypeGuildCategory:      "category",
+	discordgo.ChannelTypeGuildNews:          "announcement channel",
+	discordgo.ChannelTypeGuildNewsThread:    "announcement thread",
+	discordgo.ChannelTypeGuildPublicThread:  "public thread",
+	discordgo.ChannelTypeGuildPrivateThread: "private thread",
+	discordgo.ChannelTypeGuildStageVoice:    "stage channel",
+	discordgo.ChannelTypeGuildDirectory:     "directory",
+	discordgo.ChannelTypeGuildForum:         "forum channel",
+	discordgo.ChannelTypeGuildMedia:         "media channel",
+}
+
+func channelTypeName(t discordgo.ChannelType) string {
+	if name, ok := channelTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("channel type %d", t)
+}
+
+func isThread(t discordgo.ChannelType) bool {
+	return t == discordgo.ChannelTypeGuildNewsThread || t == discordgo.ChannelTypeGuildPublicThread || t == discordgo.ChannelTypeGuildPrivateThread
+}
+
+// restStatus returns the HTTP status and Discord message of an API error.
+func restStatus(err error) (int, string) {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		return 0, ""
+	}
+	msg := ""
+	if restErr.Message != nil {
+		msg = restErr.Message.Message
+	}
+	return restErr.Response.StatusCode, msg
+}
+
+// configFileHint names the config file in suggested fixes.
+func (c *CLI) configFileHint() string {
+	return fmt.Sprintf("~/.config/disgo/%s.yaml", c.configName)
+}
+
+// diagnose runs the doctor checks in order. Checks that depend on an
+// earlier failed one are skipped.
+func (c *CLI) diagnose(discord DiscordClient) []CheckResult {
+	var results []CheckResult
+	add := func(r CheckResult) bool {
+		results = append(results, r)
+		return r.Status != CheckFail
+	}
+	skip := func(names ...string) []CheckResult {
+		for _, name := range names {
+			results = append(results, CheckResult{Name: name, Status: CheckSkipped, Detail: "skipped after an earlier failure"})
+		}
+		return results
+	}
+
+	// Connectivity
+	if _, err := discord.Gateway(); err != nil {
+		fix := "Check that this machine can reach https://discord.com over HTTPS: DNS, firewall and proxy settings."
+		if c.config.APIBase != "" {
+			fix = fmt.Sprintf("Check that api_base %s is reachable.", c.config.APIBase)
+		}
+		add(CheckResult{Name: "connectivity", Status: CheckFail, Detail: err.Error(), Fix: fix, code: ExitFailure})
+		return skip("token", "channel", "server", "permissions")
+	}
+	add(CheckResult{Name: "connectivity", Status: CheckOK, Detail: "Discord API reachable"})
+
+	// Token
+	if c.config.Token == "" {
+		add(CheckResult{Name: "token", Status: CheckFail, Detail: "no token configured",
+			Fix:  fmt.Sprintf("Copy the bot token from the Discord Developer Portal (Bot > Reset Token) into token: in %s, or pass --token.", c.configFileHint()),
+			code: ExitConfig})
+		return skip("channel", "server", "permissions")
+	}
+	me, err := discord.User("@me")
+	if err != nil {
+		r := CheckResult{Name: "token", Status: CheckFail, Detail: err.Error(), code: ExitFailure}
+		if status, _ := restStatus(err); status == http.StatusUnauthorized {
+			r.Detail = "Discord rejected the token"
+			r.Fix = fmt.Sprintf("The token is invalid or was reset. Reset it in the Discord Developer Portal (Bot > Reset Token) and update token: in %s.", c.configFileHint())
+			r.code = ExitAuth
+		}
+		add(r)
+		return skip("channel", "server", "permissions")
+	}
+	add(CheckResult{Name: "token", Status: CheckOK, Detail: fmt.Sprintf("authenticated as %s (%s)", me.Username, me.ID)})
+
+	// Channel
+	if c.config.ChannelID == "" {
+		add(CheckResult{Name: "channel", Status: CheckFail, Detail: "no channel configured",
+			Fix:  fmt.Sprintf("Enable Developer Mode in Discord (Settings > Advanced), right-click the channel, choose Copy Channel ID and set channel_id: in %s.", c.configFileHint()),
+			code: ExitConfig})
+		return skip("server", "permissions")
+	}
+	channel, err := discord.Channel(c.config.ChannelID)
+	if err != nil {
+		r := CheckResult{Name: "channel", Status: CheckFail, Detail: err.Error(), code: ExitFailure}
+		switch status, msg := restStatus(err); status {
+		case http.StatusNotFound:
+			r.Detail = fmt.Sprintf("channel %s does not exist", c.config.ChannelID)
+			r.Fix = "Check channel_id: it must be a channel ID (Copy Channel ID with Developer Mode on), not a server or message ID."
+			r.code = ExitConfig
+		case http.StatusForbidden:
+			r.Detail = fmt.Sprintf("the bot can't see channel %s (%s)", c.config.ChannelID, msg)
+			r.Fix = "Invite the bot to the server that owns the channel, and give its role View Channel in the channel's permissions."
+			r.code = ExitPermission
+		}
+		add(r)
+		return skip("server", "permissions")
+	}
+
+	channelOK := true
+	detail := fmt.Sprintf("#%s is a %s", channel.Name, channelTypeName(channel.Type))
+	switch channel.Type {
+	case discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews:
+		add(CheckResult{Name: "channel", Status: CheckOK, Detail: detail})
+	case discordgo.ChannelTypeGuildNewsThread, discordgo.ChannelTypeGuildPublicThread, discordgo.ChannelTypeGuildPrivateThread:
+		r := CheckResult{Name: "channel", Status: CheckOK, Detail: detail}
+		if c.config.ThreadName != "" {
+			r.Status = CheckWarn
+			r.Fix = "Threads can't be created inside a thread. Unset thread_name, or point channel_id at the parent channel " + channel.ParentID + "."
+		}
+		add(r)
+	case discordgo.ChannelTypeGuildVoice, discordgo.ChannelTypeGuildStageVoice, discordgo.ChannelTypeDM, discordgo.ChannelTypeGroupDM:
+		add(CheckResult{Name: "channel", Status: CheckWarn, Detail: detail,
+			Fix: "Messages can be posted here but threads can't be created. Use a text channel for --thread."})
+	default:
+		channelOK = add(CheckResult{Name: "channel", Status: CheckFail, Detail: detail + ", which doesn't accept messages",
+			Fix:  "Point channel_id at a text or announcement channel.",
+			code: ExitConfig})
+	}
+
+	// Server
+	switch {
+	case channel.GuildID == "":
+		add(CheckResult{Name: "server", Status: CheckSkipped, Detail: "the channel is not in a server"})
+	case c.config.ServerID == "":
+		add(CheckResult{Name: "server", Status: CheckWarn, Detail: "server_id not set",
+			Fix: fmt.Sprintf("Set server_id: %s in %s to resolve mentions and custom emoji by name.", channel.GuildID, c.configFileHint())})
+	case c.config.ServerID != channel.GuildID:
+		add(CheckResult{Name: "server", Status: CheckFail,
+			Detail: fmt.Sprintf("server_id %s does not match the channel's server %s", c.config.ServerID, channel.GuildID),
+			Fix:    fmt.Sprintf("Set server_id: %s in %s, or pick a channel in server %s.", channel.GuildID, c.configFileHint(), c.config.ServerID),
+			code:   ExitConfig})
+	default:
+		add(CheckResult{Name: "server", Status: CheckOK, Detail: "server_id matches the channel's server"})
+	}
+
+	// Permissions
+	if channel.GuildID == "" || !channelOK {
+		add(CheckResult{Name: "permissions", Status: CheckSkipped, Detail: "no server permissions to check"})
+		return results
+	}
+	add(c.checkPermissions(discord, me, channel))
+	return results
+}
+
+// checkPermissions checks the bot's permissions in the channel. In a thread,
+// sending is governed by Send Messages in Threads on the parent.
+func (c *CLI) checkPermissions(discord DiscordClient, me *discordgo.User, channel *discordgo.Channel) CheckResult {
+	target := channel.ID
+	required := requiredPermissions
+	if isThread(channel.Type) && channel.ParentID != "" {
+		target = channel.ParentID
+		required = []permissionCheck{{"Send Messages in Threads", discordgo.PermissionSendMessagesInThreads, "posting in the thread"}}
+		for _, p := range requiredPermissions {
+			if p.bit != discordgo.PermissionSendMessages && p.bit != discordgo.PermissionCreatePublicThreads {
+				required = append(required, p)
+			}
+		}
+	}
+
+	perms, err := discord.UserChannelPermissions(me.ID, target)
+	if err != nil {
+		return CheckResult{Name: "permissions", Status: CheckFail, Detail: "could not read permissions: " + err.Error(),
+			Fix:  "Make sure the bot is a member of the server and can view the channel.",
+			code: ExitPermission}
+	}
+
+	var missing, uses []string
+	for _, p := range required {
+		if perms&p.bit == 0 {
+			missing = append(missing, p.name)
+			uses = append(uses, p.use)
+		}
+	}
+	if len(missing) == 0 {
+		names := make([]string, len(required))
+		for i, p := range required {
+			names[i] = p.name
+		}
+		return CheckResult{Name: "permissions", Status: CheckOK, Detail: strings.Join(names, ", ")}
+	}
+	return CheckResult{
+		Name:   "permissions",
+		Status: CheckFail,
+		Detail: fmt.Sprintf("missing %s (needed for %s)", strings.Join(missing, ", "), strings.Join(uses, "; ")),
+		Fix: fmt.Sprintf("In the channel's settings under Permissions, allow %s for the bot's role (or for %s), or grant them to the role in Server Settings > Roles.",
+			strings.Join(missing, ", "), me.Username),
+		code: ExitPermission,
+	}
+}
+
+var checkMarks = map[string]string{
+	CheckOK:      "✓",
+	CheckWarn:    "!",
+	CheckFail:    "✗",
+	CheckSkipped: "-",
+}
+
+// writeChecks prints one line per check, with the fix indented below.
+func writeChecks(w io.Writer, results []CheckResult) {
+	for _, r := range results {
+		fmt.Fprintf(w, "%s %-12s %s\n", checkMarks[r.Status], r.Name, r.Detail)
+		if r.Fix != "" {
+			fmt.Fprintf(w, "  → %s\n", r.Fix)
+		}
+	}
+}
+
+// runDoctor checks the token, channel, server and permissions of a config
+// and explains how to fix what's wrong. It exits with the code of the first
+// failed check.
+func (c *CLI) runDoctor() error {
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	results := c.diagnose(discord)
+
+	switch c.config.Output {
+	case OutputJSON:
+		data, err := json.Marshal(results)
+		if err != nil {
+			return fmt.Errorf("error encoding checks: %w", err)
+		}
+		fmt.Println(string(data))
+	case "", OutputText:
+		writeChecks(os.Stdout, results)
+	default:
+		return configErrorf("unknown output format %q (expected text or json)", c.config.Output)
+	}
+
+	failed := 0
+	code := ExitOK
+	for _, r := range results {
+		if r.Status == CheckFail {
+			if failed == 0 {
+				code = r.code
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &exitError{code: code, err: fmt.Errorf("%d check%s failed", failed, plural(failed))}
+	}
+	return nil
+}
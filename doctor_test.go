@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"disgo/fakediscord"
+)
+
+const (
+	testGuild = "900"
+	testBot   = "1000000000000000001"
+)
+
+// doctorServer returns a fake Discord with a guild, a text channel and the
+// bot as a member whose role grants perms.
+func doctorServer(perms int64) *fakediscord.Server {
+	srv := fakediscord.New()
+	srv.AddGuild(&discordgo.Guild{
+		ID:      testGuild,
+		OwnerID: "1",
+		Roles: []*discordgo.Role{
+			{ID: testGuild, Name: "@everyone", Permissions: discordgo.PermissionViewChannel},
+			{ID: "950", Name: "disgo", Permissions: perms},
+		},
+	})
+	srv.SetMembers(testGuild, []*discordgo.Member{{User: &discordgo.User{ID: testBot, Username: "disgo-bot"}, Roles: []string{"950"}}})
+	srv.AddChannel(&discordgo.Channel{ID: "100", GuildID: testGuild, Name: "alerts", Type: discordgo.ChannelTypeGuildText})
+	return srv
+}
+
+func diagnoseWith(t *testing.T, srv *fakediscord.Server, setup func(cli *CLI)) map[string]CheckResult {
+	t.Helper()
+	cli := fakeCLI(srv, "")
+	cli.config.ServerID = testGuild
+	if setup != nil {
+		setup(cli)
+	}
+	discord, err := cli.newClient()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	results := make(map[string]CheckResult)
+	for _, r := range cli.diagnose(discord) {
+		results[r.Name] = r
+	}
+	return results
+}
+
+func TestDiagnoseHealthy(t *testing.T) {
+	all := int64(discordgo.PermissionSendMessages | discordgo.PermissionCreatePublicThreads | discordgo.PermissionAttachFiles | discordgo.PermissionEmbedLinks)
+	srv := doctorServer(all)
+	defer srv.Close()
+
+	results := diagnoseWith(t, srv, nil)
+	for _, name := range []string{"connectivity", "token", "channel", "server", "permissions"} {
+		if results[name].Status != CheckOK {
+			t.Errorf("Expected %s to pass, got %+v", name, results[name])
+		}
+	}
+	if !strings.Contains(results["channel"].Detail, "#alerts is a text channel") {
+		t.Errorf("Expected the channel type, got %q", results["channel"].Detail)
+	}
+}
+
+func TestDiagnoseMissingPermissions(t *testing.T) {
+	srv := doctorServer(discordgo.PermissionSendMessages)
+	defer srv.Close()
+
+	results := diagnoseWith(t, srv, nil)
+	r := results["permissions"]
+	if r.Status != CheckFail || r.code != ExitPermission {
+		t.Fatalf("Expected a permission failure, got %+v", r)
+	}
+	if !strings.Contains(r.Detail, "Create Public Threads, Attach Files, Embed Links") || strings.Contains(r.Detail, "Send Messages,") {
+		t.Errorf("Expected exactly the missing permissions, got %q", r.Detail)
+	}
+	if r.Fix == "" {
+		t.Error("Expected a fix")
+	}
+}
+
+func TestDiagnoseChannelOverwriteDenies(t *testing.T) {
+	all := int64(discordgo.PermissionSendMessages | discordgo.PermissionCreatePublicThreads | discordgo.PermissionAttachFiles | discordgo.PermissionEmbedLinks)
+	srv := doctorServer(all)
+	defer srv.Close()
+	srv.AddChannel(&discordgo.Channel{ID: "100", GuildID: testGuild, Name: "alerts", Type: discordgo.ChannelTypeGuildText,
+		PermissionOverwrites: []*discordgo.PermissionOverwrite{
+			{ID: "950", Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionSendMessages},
+		}})
+
+	results := diagnoseWith(t, srv, nil)
+	if r := results["permissions"]; r.Status != CheckFail || !strings.Contains(r.Detail, "missing Send Messages") {
+		t.Errorf("Expected the channel overwrite to be applied, got %+v", r)
+	}
+}
+
+func TestDiagnoseFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(srv *fakediscord.Server, cli *CLI)
+		check  string
+		code   int
+		detail string
+	}{
+		{"no token", func(srv *fakediscord.Server, cli *CLI) { cli.config.Token = "" }, "token", ExitConfig, "no token"},
+		{"bad token", func(srv *fakediscord.Server, cli *CLI) {
+			srv.Inject(fakediscord.Fault{Path: "users/@me", Status: http.StatusUnauthorized})
+		}, "token", ExitAuth, "rejected"},
+		{"unknown channel", func(srv *fakediscord.Server, cli *CLI) { cli.config.ChannelID = "404" }, "channel", ExitConfig, "does not exist"},
+		{"hidden channel", func(srv *fakediscord.Server, cli *CLI) {
+			srv.Inject(fakediscord.Fault{Path: "channels/100", Status: http.StatusForbidden, Code: 50001, Message: "Missing Access"})
+		}, "channel", ExitPermission, "can't see"},
+		{"wrong server", func(srv *fakediscord.Server, cli *CLI) { cli.config.ServerID = "901" }, "server", ExitConfig, "does not match"},
+		{"forum", func(srv *fakediscord.Server, cli *CLI) {
+			srv.AddChannel(&discordgo.Channel{ID: "100", GuildID: testGuild, Name: "ideas", Type: discordgo.ChannelTypeGuildForum})
+		}, "channel", ExitConfig, "forum channel"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := doctorServer(discordgo.PermissionAll)
+			defer srv.Close()
+			results := diagnoseWith(t, srv, func(cli *CLI) { tc.setup(srv, cli) })
+			r := results[tc.check]
+			if r.Status != CheckFail || r.code != tc.code || !strings.Contains(r.Detail, tc.detail) || r.Fix == "" {
+				t.Errorf("Expected %s to fail with code %d and %q, got %+v", tc.check, tc.code, tc.detail, r)
+			}
+		})
+	}
+}
+
+func TestDiagnoseUnreachable(t *testing.T) {
+	srv := doctorServer(0)
+	srv.Close()
+
+	results := diagnoseWith(t, srv, nil)
+	if r := results["connectivity"]; r.Status != CheckFail || r.Fix == "" {
+		t.Errorf("Expected connectivity to fail with a fix, got %+v", r)
+	}
+	if r := results["permissions"]; r.Status != CheckSkipped {
+		t.Errorf("Expected later checks to be skipped, got %+v", r)
+	}
+}
+
+func TestWriteChecks(t *testing.T) {
+	var buf bytes.Buffer
+	writeChecks(&buf, []CheckResult{
+		{Name: "token", Status: CheckOK, Detail: "authenticated as bot (1)"},
+		{Name: "server", Status: CheckWarn, Detail: "server_id not set", Fix: "Set server_id: 9."},
+	})
+	expected := "✓ token        authenticated as bot (1)\n! server       server_id not set\n  → Set server_id: 9.\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
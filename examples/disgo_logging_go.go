@@ -14,7 +14,7 @@ type DisgoWriter struct {
 	Config     string
 	ThreadName string
 	Tags       string
-	Level      string // Log level for conditional tagging
+	Level      string // Log level passed to disgo --level
 }
 
 // Write satisfies io.Writer interface
@@ -25,24 +25,13 @@ func (w *DisgoWriter) Write(p []byte) (n int, err error) {
 		cmd.Args = append(cmd.Args, "--thread", w.ThreadName)
 	}
 	
-	// Base tags
-	tags := w.Tags
-	
-	// Add level-based tags if applicable
-	if w.Level == "ERROR" || w.Level == "FATAL" {
-		if tags != "" {
-			tags += ","
-		}
-		tags += "error,critical"
-	} else if w.Level == "WARN" || w.Level == "WARNING" {
-		if tags != "" {
-			tags += ","
-		}
-		tags += "warning"
+	if w.Tags != "" {
+		cmd.Args = append(cmd.Args, "--tags", w.Tags)
 	}
 	
-	if tags != "" {
-		cmd.Args = append(cmd.Args, "--tags", tags)
+	// disgo formats and routes the message by level
+	if w.Level != "" {
+		cmd.Args = append(cmd.Args, "--level", w.Level)
 	}
 	
 	// Create stdin pipe to send log content
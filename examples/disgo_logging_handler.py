@@ -36,11 +36,8 @@ class DisgoHandler(logging.Handler):
             if self.tags:
                 cmd.extend(['--tags', self.tags])
                 
-            # Add level as a tag for easier filtering
-            if record.levelno >= logging.ERROR:
-                cmd.extend(['--tags', 'error,critical'])
-            elif record.levelno >= logging.WARNING:
-                cmd.extend(['--tags', 'warning'])
+            # disgo formats and routes the message by level
+            cmd.extend(['--level', record.levelname.lower()])
                 
             process = subprocess.Popen(
                 cmd,
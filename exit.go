@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Exit codes shared by every command except ask, which keeps its own.
+const (
+	ExitOK          = 0
+	ExitFailure     = 1   // anything not covered below, e.g. network errors
+	ExitConfig      = 2   // invalid flags, arguments or configuration
+	ExitAuth        = 3   // the token was rejected
+	ExitPermission  = 4   // the bot lacks access or permissions
+	ExitRateLimited = 5   // still rate limited after retrying
+	ExitPartial     = 6   // some parts or routes were delivered, others failed
+	ExitEmptyInput  = 7   // nothing to send
+	ExitTimeout     = 8   // --timeout ran out
+	ExitInterrupted = 130 // stopped by SIGINT or SIGTERM
+)
+
+// errEmptyInput is returned when stdin holds nothing to send.
+var errEmptyInput = errors.New("nothing to send: stdin is empty")
+
+// configError marks a problem with flags, arguments or configuration that
+// retrying won't fix.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+func configErrorf(format string, args ...interface{}) error {
+	return &configError{err: fmt.Errorf(format, args...)}
+}
+
+// partialError marks a send that delivered some of its messages before
+// failing.
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// exitCode maps an error to the documented exit code.
+func exitCode(err error) int {
+	var exitErr *exitError
+	var cfgErr *configError
+	var partErr *partialError
+	var rateErr *discordgo.RateLimitError
+	var restErr *discordgo.RESTError
+	var timeoutErr *timeoutError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	// Stopping early wins over partial delivery: the receipt says what got
+	// through, the code says why the rest didn't
+	case errors.Is(err, errInterrupted):
+		return ExitInterrupted
+	case errors.As(err, &timeoutErr):
+		return ExitTimeout
+	case errors.As(err, &partErr):
+		return ExitPartial
+	case errors.Is(err, errEmptyInput):
+		return ExitEmptyInput
+	case errors.As(err, &cfgErr):
+		return ExitConfig
+	case errors.As(err, &rateErr):
+		return ExitRateLimited
+	case errors.As(err, &restErr) && restErr.Response != nil:
+		switch restErr.Response.StatusCode {
+		case http.StatusUnauthorized:
+			return ExitAuth
+		case http.StatusForbidden:
+			return ExitPermission
+		case http.StatusTooManyRequests:
+			return ExitRateLimited
+		}
+	}
+	return ExitFailure
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"disgo/fakediscord"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"success", nil, ExitOK},
+		{"generic", errors.New("connection refused"), ExitFailure},
+		{"config", configErrorf("discord token not configured"), ExitConfig},
+		{"wrapped config", fmt.Errorf("route a: %w", configErrorf("invalid webhook URL")), ExitConfig},
+		{"empty input", errEmptyInput, ExitEmptyInput},
+		{"partial", &partialError{err: configErrorf("x")}, ExitPartial},
+		{"explicit", &exitError{code: AskExitTimeout}, AskExitTimeout},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitCode(tc.err); got != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+
+	ask := commands["ask"]
+	if got := ask.exitCode(configErrorf("bad config")); got != AskExitError {
+		t.Errorf("Expected ask to keep its error code, got %d", got)
+	}
+	if got := ask.exitCode(&exitError{code: AskExitDenied}); got != AskExitDenied {
+		t.Errorf("Expected ask decisions to keep their code, got %d", got)
+	}
+
+	// A flag typo must not look like ask timing out
+	cli := NewCLI()
+	cli.flags.SetOutput(io.Discard)
+	err := cli.parseFlags([]string{"x", "--bogus"})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown flag")
+	}
+	if got := ask.exitCode(&configError{err: err}); got != AskExitError {
+		t.Errorf("Expected ask to exit %d for a bad flag, got %d", AskExitError, got)
+	}
+}
+
+// runSendJSON runs a send against srv and returns the JSON receipt printed.
+func runSendJSON(t *testing.T, srv *fakediscord.Server, cli *CLI) (*Receipt, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+
+	cli.config.Output = OutputJSON
+	sendErr := cli.runSend()
+	w.Close()
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	if buf.Len() == 0 {
+		return nil, sendErr
+	}
+	var receipt Receipt
+	if err := json.Unmarshal(buf.Bytes(), &receipt); err != nil {
+		t.Fatalf("Receipt is not valid JSON: %v (%q)", err, buf.String())
+	}
+	return &receipt, sendErr
+}
+
+func TestSendExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    fakediscord.Fault
+		expected int
+	}{
+		{"auth", fakediscord.Fault{Status: http.StatusUnauthorized}, ExitAuth},
+		{"permission", fakediscord.Fault{Status: http.StatusForbidden, Code: 50013, Message: "Missing Permissions"}, ExitPermission},
+		{"rate limited", fakediscord.Fault{Status: http.StatusTooManyRequests}, ExitRateLimited},
+		{"partial", fakediscord.Fault{Status: http.StatusInternalServerError, After: 1}, ExitPartial},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := fakediscord.New()
+			defer srv.Close()
+			srv.Inject(tc.fault)
+
+			cli := fakeCLI(srv, "part one\npart two\n")
+			cli.config.MaxMessageSize = 10
+			receipt, err := runSendJSON(t, srv, cli)
+			if got := exitCode(err); got != tc.expected {
+				t.Fatalf("Expected exit code %d, got %d (%v)", tc.expected, got, err)
+			}
+			if receipt == nil || receipt.Error == "" || receipt.Parts != 2 {
+				t.Fatalf("Expected a receipt reporting the failure, got %+v", receipt)
+			}
+			if receipt.PartsSent+receipt.PartsFailed != 2 {
+				t.Errorf("Expected sent and failed parts to add up, got %+v", receipt)
+			}
+		})
+	}
+
+	srv := fakediscord.New()
+	defer srv.Close()
+	if _, err := runSendJSON(t, srv, fakeCLI(srv, " \n")); exitCode(err) != ExitEmptyInput {
+		t.Errorf("Expected empty input exit code, got %v", err)
+	}
+	if len(srv.Requests()) != 0 {
+		t.Errorf("Expected no requests for empty input, got %d", len(srv.Requests()))
+	}
+}
+
+func TestSendReceiptJSON(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "part one\npart two\n")
+	cli.config.MaxMessageSize = 10
+	cli.config.ThreadName = "ci"
+	receipt, err := runSendJSON(t, srv, cli)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if receipt.ChannelID != "100" || receipt.ThreadID == "" || receipt.StarterID == "" {
+		t.Errorf("Expected channel, thread and starter, got %+v", receipt)
+	}
+	if receipt.Parts != 2 || receipt.PartsSent != 2 || receipt.PartsFailed != 0 || len(receipt.MessageIDs) != 2 {
+		t.Errorf("Expected both parts sent, got %+v", receipt)
+	}
+	if receipt.StartedAt.IsZero() || receipt.DurationMS < 0 {
+		t.Errorf("Expected timings, got %+v", receipt)
+	}
+}
@@ -0,0 +1,622 @@
+// Package fakediscord is an in-process stand-in for the Discord REST API,
+// built on httptest. It keeps channels, messages, threads, reactions and
+// polls in memory, records every request and can inject rate limits and
+// errors.
+//
+// Point disgo at it with --api-base (or api_base in a config):
+//
+//	srv := fakediscord.New()
+//	defer srv.Close()
+//	// disgo --api-base srv.URL ...
+package fakediscord
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord error codes returned for missing resources.
+const (
+	CodeUnknownChannel = 10003
+	CodeUnknownMessage = 10008
+)
+
+// firstID is the first snowflake handed out, so IDs look like Discord's.
+const firstID = 1100000000000000000
+
+// Request is a request the server received. Body is the JSON payload; for
+// multipart requests it is the payload_json part.
+type Request struct {
+	Method string
+	Path   string // relative to the API root, e.g. channels/123/messages
+	Query  url.Values
+	Body   []byte
+}
+
+// Fault makes matching requests fail instead of being served, or hang
+// first with Delay.
+type Fault struct {
+	Method     string        // matches any method when empty
+	Path       string        // matches paths containing this; any path when empty
+	Status     int           // HTTP status to return; 0 serves the request after Delay
+	Delay      time.Duration // how long to wait before responding
+	Message    string        // error message; defaults to the status text
+	Code       int           // Discord error code
+	RetryAfter time.Duration // for 429s; defaults to 10ms
+	Times      int           // how many requests fail; every one when 0
+	After      int           // matching requests to let through first
+}
+
+func (f *Fault) matches(method, path string) bool {
+	return (f.Method == "" || strings.EqualFold(f.Method, method)) && strings.Contains(path, f.Path)
+}
+
+// Server is a fake Discord REST API.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nextID   uint64
+	user     *discordgo.User
+	requests []Request
+	faults   []*Fault
+	guilds   map[string]*discordgo.Guild
+	channels map[string]*discordgo.Channel
+	messages map[string][]*discordgo.Message
+	roles    map[string][]*discordgo.Role
+	emojis   map[string][]*discordgo.Emoji
+	members  map[string][]*discordgo.Member
+	polls    map[string]*Poll
+}
+
+// New starts a fake Discord server. Close it when done.
+func New() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// NewTLS starts a fake Discord server on HTTPS with a self-signed
+// certificate, available from Certificate.
+func NewTLS() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		nextID:   firstID,
+		user:     &discordgo.User{ID: "1000000000000000001", Username: "disgo-bot", Bot: true},
+		guilds:   make(map[string]*discordgo.Guild),
+		channels: make(map[string]*discordgo.Channel),
+		messages: make(map[string][]*discordgo.Message),
+		roles:    make(map[string][]*discordgo.Role),
+		emojis:   make(map[string][]*discordgo.Emoji),
+		members:  make(map[string][]*discordgo.Member),
+		polls:    make(map[string]*Poll),
+	}
+}
+
+// Inject adds a fault. Faults are checked in the order they were added.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// RateLimit makes the next n requests matching method and path return 429
+// with the given retry_after.
+func (s *Server) RateLimit(method, path string, n int, retryAfter time.Duration) {
+	s.Inject(Fault{Method: method, Path: path, Status: http.StatusTooManyRequests, RetryAfter: retryAfter, Times: n})
+}
+
+// SetUser sets the user returned for the bot token.
+func (s *Server) SetUser(u *discordgo.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+// AddChannel registers a channel. Channels messages are posted to are
+// created on demand as guild text channels.
+func (s *Server) AddChannel(ch *discordgo.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[ch.ID] = ch
+}
+
+// AddGuild registers a guild, with its roles, for permission lookups.
+func (s *Server) AddGuild(g *discordgo.Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[g.ID] = g
+	s.roles[g.ID] = g.Roles
+}
+
+// SetRoles sets the roles of a guild.
+func (s *Server) SetRoles(guildID string, roles []*discordgo.Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles[guildID] = roles
+}
+
+// SetEmojis sets the custom emoji of a guild.
+func (s *Server) SetEmojis(guildID string, emojis []*discordgo.Emoji) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emojis[guildID] = emojis
+}
+
+// SetMembers sets the members of a guild, for member search.
+func (s *Server) SetMembers(guildID string, members []*discordgo.Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[guildID] = members
+}
+
+// Requests returns the requests received so far, including failed ones.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Messages returns the messages in a channel or thread, oldest first.
+// Messages sent through a webhook are kept under the thread they were sent
+// to, or under the webhook ID.
+func (s *Server) Messages(channelID string) []*discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.Message(nil), s.messages[channelID]...)
+}
+
+// Channel returns a channel or thread by ID.
+func (s *Server) Channel(channelID string) *discordgo.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channels[channelID]
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return strconv.FormatUint(s.nextID, 10)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	prefix := "/api/v" + discordgo.APIVersion + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, http.StatusNotFound, 0, "")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Query: r.URL.Query(), Body: body})
+	f := s.takeFault(r.Method, path)
+	s.mu.Unlock()
+
+	if f != nil && f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f != nil && f.Status != 0 {
+		if f.Status == http.StatusTooManyRequests {
+			retryAfter := f.RetryAfter
+			if retryAfter <= 0 {
+				retryAfter = 10 * time.Millisecond
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.999)))
+			writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+				"message":     "You are being rate limited.",
+				"retry_after": retryAfter.Seconds(),
+				"global":      false,
+			})
+			return
+		}
+		writeError(w, f.Status, f.Code, f.Message)
+		return
+	}
+
+	s.route(w, r.Method, strings.Split(path, "/"), r.URL.Query(), body)
+}
+
+// takeFault returns the first fault matching the request, using up one of
+// its failures.
+func (s *Server) takeFault(method, path string) *Fault {
+	for i, f := range s.faults {
+		if !f.matches(method, path) {
+			continue
+		}
+		if f.After > 0 {
+			f.After--
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) route(w http.ResponseWriter, method string, p []string, query url.Values, body []byte) {
+	switch {
+	case method == http.MethodGet && len(p) == 1 && p[0] == "gateway":
+		writeJSON(w, http.StatusOK, map[string]string{"url": "wss://gateway.discord.gg"})
+
+	case method == http.MethodGet && len(p) == 2 && p[0] == "users":
+		if p[1] != "@me" && p[1] != s.user.ID {
+			writeError(w, http.StatusNotFound, 10013, "Unknown User")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.user)
+
+	case method == http.MethodGet && len(p) == 2 && p[0] == "channels":
+		ch, ok := s.channels[p[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, CodeUnknownChannel, "Unknown Channel")
+			return
+		}
+		writeJSON(w, http.StatusOK, ch)
+
+	case len(p) == 3 && p[0] == "channels" && p[2] == "messages":
+		switch method {
+		case http.MethodPost:
+			s.createMessage(w, p[1], body)
+		case http.MethodGet:
+			s.listMessages(w, p[1], query)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, 0, "")
+		}
+
+	case len(p) == 4 && p[0] == "channels" && p[2] == "messages":
+		switch method {
+		case http.MethodGet:
+			s.getMessage(w, p[1], p[3])
+		case http.MethodPatch:
+			s.editMessage(w, p[1], p[3], body)
+		case http.MethodDelete:
+			s.deleteMessage(w, p[1], p[3])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, 0, "")
+		}
+
+	case method == http.MethodPost && len(p) == 5 && p[0] == "channels" && p[2] == "messages" && p[4] == "threads":
+		s.startThread(w, p[1], p[3], body)
+
+	case len(p) == 7 && p[0] == "channels" && p[2] == "messages" && p[4] == "reactions":
+		s.react(w, method, p[1], p[3], p[5])
+
+	case method == http.MethodPost && len(p) == 3 && p[0] == "webhooks":
+		s.executeWebhook(w, p[1], query, body)
+
+	case method == http.MethodGet && len(p) == 2 && p[0] == "guilds":
+		g, ok := s.guilds[p[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, 10004, "Unknown Guild")
+			return
+		}
+		guild := *g
+		guild.Roles = s.roles[g.ID]
+		writeJSON(w, http.StatusOK, &guild)
+
+	case method == http.MethodGet && len(p) == 4 && p[0] == "guilds" && p[2] == "members" && p[3] != "search":
+		for _, m := range s.members[p[1]] {
+			if m.User != nil && m.User.ID == p[3] {
+				writeJSON(w, http.StatusOK, m)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, 10007, "Unknown Member")
+
+	case method == http.MethodGet && len(p) == 3 && p[0] == "guilds" && p[2] == "roles":
+		writeJSON(w, http.StatusOK, nonNil(s.roles[p[1]]))
+
+	case method == http.MethodGet && len(p) == 3 && p[0] == "guilds" && p[2] == "emojis":
+		writeJSON(w, http.StatusOK, nonNil(s.emojis[p[1]]))
+
+	case method == http.MethodGet && len(p) == 4 && p[0] == "guilds" && p[2] == "members" && p[3] == "search":
+		s.searchMembers(w, p[1], query)
+
+	default:
+		writeError(w, http.StatusNotFound, 0, "")
+	}
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, channelID string, body []byte) {
+	var data discordgo.MessageSend
+	var extra struct {
+		Poll *pollCreate `json:"poll"`
+	}
+	if json.Unmarshal(body, &data) != nil || json.Unmarshal(body, &extra) != nil {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return
+	}
+	if data.Content == "" && len(data.Embeds) == 0 && extra.Poll == nil {
+		writeError(w, http.StatusBadRequest, 50006, "Cannot send an empty message")
+		return
+	}
+	var poll *Poll
+	if extra.Poll != nil {
+		var err error
+		if poll, err = extra.Poll.poll(); err != nil {
+			writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body: "+err.Error())
+			return
+		}
+	}
+	if _, ok := s.channels[channelID]; !ok {
+		s.channels[channelID] = &discordgo.Channel{ID: channelID, Type: discordgo.ChannelTypeGuildText}
+	}
+	msg := s.store(channelID, data.Content, data.Embeds)
+	msg.MessageReference = data.Reference
+	if poll != nil {
+		s.polls[msg.ID] = poll
+	}
+	writeJSON(w, http.StatusOK, s.withPoll(msg))
+}
+
+// getMessage returns a single message, with its poll if it has one.
+func (s *Server) getMessage(w http.ResponseWriter, channelID, messageID string) {
+	_, msg := s.findMessage(channelID, messageID)
+	if msg == nil {
+		writeError(w, http.StatusNotFound, CodeUnknownMessage, "Unknown Message")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.withPoll(msg))
+}
+
+func (s *Server) store(channelID, content string, embeds []*discordgo.MessageEmbed) *discordgo.Message {
+	msg := &discordgo.Message{
+		ID:        s.newID(),
+		ChannelID: channelID,
+		Content:   content,
+		Embeds:    embeds,
+		Author:    s.user,
+		Timestamp: time.Now().UTC(),
+	}
+	s.messages[channelID] = append(s.messages[channelID], msg)
+	return msg
+}
+
+func (s *Server) findMessage(channelID, messageID string) (int, *discordgo.Message) {
+	for i, m := range s.messages[channelID] {
+		if m.ID == messageID {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+// listMessages returns messages newest first, honouring limit and before.
+func (s *Server) listMessages(w http.ResponseWriter, channelID string, query url.Values) {
+	limit := 50
+	if n, err := strconv.Atoi(query.Get("limit")); err == nil && n > 0 && n <= 100 {
+		limit = n
+	}
+	msgs := s.messages[channelID]
+	if before := query.Get("before"); before != "" {
+		if i, _ := s.findMessage(channelID, before); i >= 0 {
+			msgs = msgs[:i]
+		}
+	}
+	page := []*discordgo.Message{}
+	for i := len(msgs) - 1; i >= 0 && len(page) < limit; i-- {
+		page = append(page, msgs[i])
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, channelID, messageID string, body []byte) {
+	_, msg := s.findMessage(channelID, messageID)
+	if msg == nil {
+		writeError(w, http.StatusNotFound, CodeUnknownMessage, "Unknown Message")
+		return
+	}
+	var data discordgo.MessageEdit
+	if err := json.Unmarshal(body, &data); err != nil {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return
+	}
+	if data.Content != nil {
+		msg.Content = *data.Content
+	}
+	if data.Embeds != nil {
+		msg.Embeds = *data.Embeds
+	}
+	now := time.Now().UTC()
+	msg.EditedTimestamp = &now
+	writeJSON(w, http.StatusOK, msg)
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, channelID, messageID string) {
+	i, _ := s.findMessage(channelID, messageID)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, CodeUnknownMessage, "Unknown Message")
+		return
+	}
+	msgs := s.messages[channelID]
+	s.messages[channelID] = append(msgs[:i:i], msgs[i+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) startThread(w http.ResponseWriter, channelID, messageID string, body []byte) {
+	_, msg := s.findMessage(channelID, messageID)
+	if msg == nil {
+		writeError(w, http.StatusNotFound, CodeUnknownMessage, "Unknown Message")
+		return
+	}
+	var data discordgo.ThreadStart
+	if err := json.Unmarshal(body, &data); err != nil || data.Name == "" {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return
+	}
+	parent, ok := s.channels[channelID]
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeUnknownChannel, "Unknown Channel")
+		return
+	}
+	thread := &discordgo.Channel{
+		// Threads started from a message share its ID
+		ID:       msg.ID,
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+		Name:     data.Name,
+		ParentID: channelID,
+		GuildID:  parent.GuildID,
+		ThreadMetadata: &discordgo.ThreadMetadata{
+			AutoArchiveDuration: data.AutoArchiveDuration,
+		},
+	}
+	s.channels[thread.ID] = thread
+	msg.Thread = thread
+	writeJSON(w, http.StatusCreated, thread)
+}
+
+func (s *Server) react(w http.ResponseWriter, method, channelID, messageID, emoji string) {
+	_, msg := s.findMessage(channelID, messageID)
+	if msg == nil {
+		writeError(w, http.StatusNotFound, CodeUnknownMessage, "Unknown Message")
+		return
+	}
+	var existing *discordgo.MessageReactions
+	for _, r := range msg.Reactions {
+		if reactionKey(r.Emoji) == emoji {
+			existing = r
+		}
+	}
+
+	switch method {
+	case http.MethodPut:
+		if existing == nil {
+			name, id, _ := strings.Cut(emoji, ":")
+			existing = &discordgo.MessageReactions{Emoji: &discordgo.Emoji{Name: name, ID: id}}
+			msg.Reactions = append(msg.Reactions, existing)
+		}
+		if !existing.Me {
+			existing.Me = true
+			existing.Count++
+		}
+	case http.MethodDelete:
+		if existing != nil && existing.Me {
+			existing.Me = false
+			existing.Count--
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reactionKey is how the reaction endpoints name an emoji.
+func reactionKey(e *discordgo.Emoji) string {
+	if e.ID != "" {
+		return e.Name + ":" + e.ID
+	}
+	return e.Name
+}
+
+func (s *Server) executeWebhook(w http.ResponseWriter, webhookID string, query url.Values, body []byte) {
+	var data discordgo.WebhookParams
+	if err := json.Unmarshal(body, &data); err != nil {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return
+	}
+	channelID := webhookID
+	if threadID := query.Get("thread_id"); threadID != "" {
+		channelID = threadID
+	}
+	msg := s.store(channelID, data.Content, data.Embeds)
+	msg.WebhookID = webhookID
+	if data.Username != "" {
+		msg.Author = &discordgo.User{ID: webhookID, Username: data.Username, Bot: true}
+	}
+	if query.Get("wait") != "true" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+func (s *Server) searchMembers(w http.ResponseWriter, guildID string, query url.Values) {
+	q := strings.ToLower(query.Get("query"))
+	found := []*discordgo.Member{}
+	for _, m := range s.members[guildID] {
+		if m.User == nil {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(m.User.Username), q) || strings.HasPrefix(strings.ToLower(m.Nick), q) {
+			found = append(found, m)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].User.Username < found[j].User.Username })
+	writeJSON(w, http.StatusOK, found)
+}
+
+// readBody returns the JSON payload of a request.
+func readBody(r *http.Request) ([]byte, error) {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return io.ReadAll(r.Body)
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+		if part.FormName() == "payload_json" {
+			return io.ReadAll(part)
+		}
+	}
+}
+
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	if message == "" {
+		message = fmt.Sprintf("%d: %s", status, http.StatusText(status))
+	}
+	writeJSON(w, status, map[string]interface{}{"message": message, "code": code})
+}
@@ -0,0 +1,144 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func do(t *testing.T, s *Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+"/api/v9/"+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestMessages(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	for _, content := range []string{"one", "two", "three"} {
+		if resp := do(t, s, "POST", "channels/1/messages", `{"content":"`+content+`"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+	msgs := s.Messages("1")
+	if len(msgs) != 3 || msgs[0].Content != "one" || msgs[2].Content != "three" {
+		t.Fatalf("Expected three messages in order, got %+v", msgs)
+	}
+
+	resp := do(t, s, "GET", "channels/1/messages?limit=2&before="+msgs[2].ID, "")
+	var page []struct{ Content string }
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+	if len(page) != 2 || page[0].Content != "two" || page[1].Content != "one" {
+		t.Errorf("Expected older messages newest first, got %+v", page)
+	}
+
+	do(t, s, "PATCH", "channels/1/messages/"+msgs[0].ID, `{"content":"edited"}`)
+	do(t, s, "DELETE", "channels/1/messages/"+msgs[1].ID, "")
+	msgs = s.Messages("1")
+	if len(msgs) != 2 || msgs[0].Content != "edited" {
+		t.Errorf("Expected edit and delete to apply, got %+v", msgs)
+	}
+
+	if resp := do(t, s, "DELETE", "channels/1/messages/42", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown message, got %d", resp.StatusCode)
+	}
+	if resp := do(t, s, "POST", "channels/1/messages", `{}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty message, got %d", resp.StatusCode)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	s.RateLimit("POST", "messages", 1, 250*time.Millisecond)
+	s.Inject(Fault{Path: "users/@me", Status: http.StatusUnauthorized})
+
+	resp := do(t, s, "POST", "channels/1/messages", `{"content":"x"}`)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", resp.StatusCode)
+	}
+	var body struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.RetryAfter != 0.25 || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("Expected retry_after 0.25 and header 1, got %v %q", body.RetryAfter, resp.Header.Get("Retry-After"))
+	}
+
+	if resp := do(t, s, "POST", "channels/1/messages", `{"content":"x"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the rate limit to be used up, got %d", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		if resp := do(t, s, "GET", "users/@me", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected a permanent 401, got %d", resp.StatusCode)
+		}
+	}
+	if got := len(s.Requests()); got != 4 {
+		t.Errorf("Expected 4 recorded requests, got %d", got)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	s.Inject(Fault{Path: "messages", Delay: 50 * time.Millisecond, Times: 1})
+	start := time.Now()
+	if resp := do(t, s, "POST", "channels/1/messages", `{"content":"slow"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected a delayed fault without a status to be served, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the response to be delayed, took %s", elapsed)
+	}
+	if len(s.Messages("1")) != 1 {
+		t.Errorf("Expected the message to be stored")
+	}
+}
+
+func TestThreadsReactionsWebhooks(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	do(t, s, "POST", "channels/1/messages", `{"content":"starter"}`)
+	starter := s.Messages("1")[0]
+	resp := do(t, s, "POST", "channels/1/messages/"+starter.ID+"/threads", `{"name":"ops","auto_archive_duration":60}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", resp.StatusCode)
+	}
+	thread := s.Channel(starter.ID)
+	if thread == nil || thread.Name != "ops" || thread.ParentID != "1" {
+		t.Fatalf("Expected thread under the channel, got %+v", thread)
+	}
+
+	do(t, s, "PUT", "channels/1/messages/"+starter.ID+"/reactions/✅/@me", "")
+	do(t, s, "PUT", "channels/1/messages/"+starter.ID+"/reactions/party:123/@me", "")
+	do(t, s, "DELETE", "channels/1/messages/"+starter.ID+"/reactions/✅/@me", "")
+	reactions := s.Messages("1")[0].Reactions
+	if len(reactions) != 2 || reactions[0].Count != 0 || reactions[1].Emoji.ID != "123" || !reactions[1].Me {
+		t.Errorf("Expected one remaining custom reaction, got %+v", reactions)
+	}
+
+	resp = do(t, s, "POST", "webhooks/9/token?wait=true&thread_id="+thread.ID, `{"content":"hook","username":"ci"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	msgs := s.Messages(thread.ID)
+	if len(msgs) != 1 || msgs[0].Author.Username != "ci" || msgs[0].WebhookID != "9" {
+		t.Errorf("Expected the webhook message in the thread, got %+v", msgs)
+	}
+}
@@ -0,0 +1,126 @@
+package fakediscord
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// PollMedia is the text of a poll question or answer.
+type PollMedia struct {
+	Text string `json:"text"`
+}
+
+// PollAnswer is one of the answers of a poll.
+type PollAnswer struct {
+	AnswerID  int       `json:"answer_id"`
+	PollMedia PollMedia `json:"poll_media"`
+}
+
+// PollAnswerCount is the number of votes for an answer.
+type PollAnswerCount struct {
+	ID      int  `json:"id"`
+	Count   int  `json:"count"`
+	MeVoted bool `json:"me_voted"`
+}
+
+// PollResults are the votes of a poll so far.
+type PollResults struct {
+	IsFinalized  bool              `json:"is_finalized"`
+	AnswerCounts []PollAnswerCount `json:"answer_counts"`
+}
+
+// Poll is a poll attached to a message, as Discord returns it.
+type Poll struct {
+	Question         PollMedia    `json:"question"`
+	Answers          []PollAnswer `json:"answers"`
+	Expiry           time.Time    `json:"expiry"`
+	AllowMultiselect bool         `json:"allow_multiselect"`
+	LayoutType       int          `json:"layout_type"`
+	Results          PollResults  `json:"results"`
+}
+
+// pollCreate is the poll of a create message request.
+type pollCreate struct {
+	Question         PollMedia    `json:"question"`
+	Answers          []PollAnswer `json:"answers"`
+	Duration         int          `json:"duration"`
+	AllowMultiselect bool         `json:"allow_multiselect"`
+	LayoutType       int          `json:"layout_type"`
+}
+
+// poll validates a poll the way Discord does and numbers its answers.
+func (p *pollCreate) poll() (*Poll, error) {
+	switch {
+	case p.Question.Text == "" || len([]rune(p.Question.Text)) > 300:
+		return nil, errors.New("poll question must be 1 to 300 characters")
+	case len(p.Answers) == 0 || len(p.Answers) > 10:
+		return nil, errors.New("poll must have 1 to 10 answers")
+	case p.Duration < 1 || p.Duration > 768:
+		return nil, errors.New("poll duration must be 1 to 768 hours")
+	}
+	poll := &Poll{
+		Question:         p.Question,
+		Expiry:           time.Now().UTC().Add(time.Duration(p.Duration) * time.Hour),
+		AllowMultiselect: p.AllowMultiselect,
+		LayoutType:       1,
+		Results:          PollResults{AnswerCounts: []PollAnswerCount{}},
+	}
+	for i, a := range p.Answers {
+		if a.PollMedia.Text == "" || len([]rune(a.PollMedia.Text)) > 55 {
+			return nil, errors.New("poll answers must be 1 to 55 characters")
+		}
+		poll.Answers = append(poll.Answers, PollAnswer{AnswerID: i + 1, PollMedia: a.PollMedia})
+	}
+	return poll, nil
+}
+
+// Poll returns the poll of a message, or nil if it has none.
+func (s *Server) Poll(messageID string) *Poll {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls[messageID]
+}
+
+// Vote adds votes for an answer of a poll, by its answer ID. Like Discord,
+// answers without votes are left out of the results.
+func (s *Server) Vote(messageID string, answerID, votes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll := s.polls[messageID]
+	if poll == nil {
+		return
+	}
+	counts := poll.Results.AnswerCounts
+	for i := range counts {
+		if counts[i].ID == answerID {
+			counts[i].Count += votes
+			return
+		}
+	}
+	poll.Results.AnswerCounts = append(counts, PollAnswerCount{ID: answerID, Count: votes})
+}
+
+// EndPoll closes a poll and finalizes its results.
+func (s *Server) EndPoll(messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if poll := s.polls[messageID]; poll != nil {
+		poll.Expiry = time.Now().UTC()
+		poll.Results.IsFinalized = true
+	}
+}
+
+// withPoll adds a message's poll to its JSON, since discordgo has no field
+// for it.
+func (s *Server) withPoll(msg *discordgo.Message) interface{} {
+	poll := s.polls[msg.ID]
+	if poll == nil {
+		return msg
+	}
+	return struct {
+		*discordgo.Message
+		Poll *Poll `json:"poll"`
+	}{msg, poll}
+}
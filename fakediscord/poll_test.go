@@ -0,0 +1,59 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPolls(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	resp := do(t, s, "POST", "channels/1/messages", `{"poll":{"question":{"text":"Lunch?"},"answers":[{"poll_media":{"text":"Pizza"}},{"poll_media":{"text":"Sushi"}}],"duration":4}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var created struct {
+		ID   string
+		Poll *Poll
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+	if created.Poll == nil || created.Poll.Question.Text != "Lunch?" || len(created.Poll.Answers) != 2 || created.Poll.Answers[1].AnswerID != 2 {
+		t.Fatalf("Expected the poll with numbered answers, got %+v", created.Poll)
+	}
+
+	s.Vote(created.ID, 2, 3)
+	s.Vote(created.ID, 2, 1)
+	s.EndPoll(created.ID)
+
+	resp = do(t, s, "GET", "channels/1/messages/"+created.ID, "")
+	var got struct{ Poll *Poll }
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+	results := got.Poll.Results
+	if !results.IsFinalized || len(results.AnswerCounts) != 1 || results.AnswerCounts[0] != (PollAnswerCount{ID: 2, Count: 4}) {
+		t.Errorf("Expected 4 votes for answer 2 in final results, got %+v", results)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no answers", `{"poll":{"question":{"text":"Q"},"answers":[],"duration":1}}`},
+		{"too long", `{"poll":{"question":{"text":"Q"},"answers":[{"poll_media":{"text":"A"}}],"duration":769}}`},
+		{"no question", `{"poll":{"question":{"text":""},"answers":[{"poll_media":{"text":"A"}}],"duration":1}}`},
+	}
+	for _, tt := range tests {
+		if resp := do(t, s, "POST", "channels/1/messages", tt.body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: Expected 400, got %d", tt.name, resp.StatusCode)
+		}
+	}
+
+	if resp := do(t, s, "GET", "channels/1/messages/42", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown message, got %d", resp.StatusCode)
+	}
+}
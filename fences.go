@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const fenceMarker = "```"
+
+// fenceState tracks whether the text so far has left a code block open.
+type fenceState struct {
+	open bool
+	lang string
+	sgr  sgrState // color state inside ```ansi blocks
+}
+
+// update moves the state past one line of text.
+func (f fenceState) update(line string) fenceState {
+	return f.advance(line, true)
+}
+
+// advance moves the state past text. Only text at the start of a line can
+// open or close a block: the rest of a long line cut into pieces is content,
+// even when a piece happens to start with ```.
+func (f fenceState) advance(text string, lineStart bool) fenceState {
+	trimmed := strings.TrimSpace(text)
+	if lineStart && strings.HasPrefix(trimmed, fenceMarker) {
+		if f.open {
+			return fenceState{}
+		}
+		return fenceState{open: true, lang: strings.TrimSpace(strings.TrimPrefix(trimmed, fenceMarker))}
+	}
+	if f.open && f.lang == "ansi" {
+		f.sgr = trackSGR(f.sgr, text)
+	}
+	return f
+}
+
+// reopen is what a new part starts with to continue an open block.
+func (f fenceState) reopen() string {
+	if !f.open {
+		return ""
+	}
+	prefix := fenceMarker + f.lang + "\n"
+	if f.lang == "ansi" && f.sgr != (sgrState{}) {
+		prefix += f.sgr.sequence()
+	}
+	return prefix
+}
+
+// closing is what a part ending inside an open block needs appended.
+func (f fenceState) closing(part string) string {
+	if !f.open {
+		return ""
+	}
+	if strings.HasSuffix(part, "\n") {
+		return fenceMarker
+	}
+	return "\n" + fenceMarker
+}
+
+// hasFences reports whether content contains a code block marker.
+func hasFences(content string) bool {
+	return strings.Contains(content, fenceMarker)
+}
+
+// splitFenced splits content at line boundaries like splitMessage, but
+// closes a code block that is open at the end of a part and reopens it, with
+// the same language and ansi colors, at the start of the next. The added
+// fences count toward maxSize.
+func splitFenced(content string, maxSize int) []string {
+	lines := strings.SplitAfter(content, "\n")
+
+	var parts []string
+	var cur strings.Builder
+	state := fenceState{} // state at the end of cur
+	hasText := false      // cur holds more than the reopening fence
+
+	flush := func() {
+		part := cur.String()
+		parts = append(parts, part+state.closing(part))
+		cur.Reset()
+		cur.WriteString(state.reopen())
+		hasText = false
+	}
+
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		next := state.update(line)
+		if hasText && cur.Len()+len(line)+len(next.closing(line)) > maxSize {
+			flush()
+		}
+
+		// A line too long for any part is cut at rune boundaries. The
+		// room left depends on whether the part is in a block after the
+		// cut, which a fence at the start of the line changes.
+		lineStart := true
+		for cur.Len()+len(line)+len(next.closing(line)) > maxSize {
+			closing := state.closing("x")
+			if after := state.advance(line, lineStart); after.open {
+				closing = after.closing("x")
+			}
+			room := maxSize - cur.Len() - len(closing)
+			cut := 0
+			for cut < len(line) {
+				_, size := utf8.DecodeRuneInString(line[cut:])
+				if cut+size > room {
+					break
+				}
+				cut += size
+			}
+			if cut == 0 {
+				// Not even one rune fits next to the fences
+				break
+			}
+			cur.WriteString(line[:cut])
+			state = state.advance(line[:cut], lineStart)
+			hasText = true
+			lineStart = false
+			line = line[cut:]
+			next = state.advance(line, false)
+			flush()
+		}
+
+		cur.WriteString(line)
+		state = next
+		hasText = true
+	}
+	if hasText {
+		part := cur.String()
+		parts = append(parts, part+state.closing(part))
+	}
+	if len(parts) == 0 {
+		return []string{content}
+	}
+	return parts
+}
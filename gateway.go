@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// openGateway connects the session to the Discord gateway with the given
+// intents. Handlers must be registered before calling it so that no events
+// are missed.
+func (c *CLI) openGateway(discord *discordgo.Session, intents discordgo.Intent) error {
+	discord.Identify.Intents = intents
+	if c.config.Debug {
+		discord.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+			log.Printf("Connected to gateway as %s (%s)", r.User.Username, r.User.ID)
+		})
+	}
+	if err := discord.Open(); err != nil {
+		return fmt.Errorf("error opening gateway connection: %w", err)
+	}
+	return nil
+}
+
+// approverSet restricts who may respond to an interaction. An empty set
+// allows everyone.
+type approverSet struct {
+	users map[string]bool
+	roles map[string]bool
+}
+
+func newApproverSet(users, roles []string) approverSet {
+	a := approverSet{users: make(map[string]bool), roles: make(map[string]bool)}
+	for _, u := range users {
+		a.users[u] = true
+	}
+	for _, r := range roles {
+		a.roles[r] = true
+	}
+	return a
+}
+
+func (a approverSet) empty() bool {
+	return len(a.users) == 0 && len(a.roles) == 0
+}
+
+// allows reports whether a user with the given roles is authorized.
+func (a approverSet) allows(userID string, roles []string) bool {
+	if a.empty() || a.users[userID] {
+		return true
+	}
+	for _, r := range roles {
+		if a.roles[r] {
+			return true
+		}
+	}
+	return false
+}
+
+// interactionUser returns the user behind an interaction, whether it came
+// from a guild or a DM, along with their guild roles.
+func interactionUser(i *discordgo.InteractionCreate) (*discordgo.User, []string) {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User, i.Member.Roles
+	}
+	return i.User, nil
+}
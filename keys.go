@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// KeyedMessage remembers the messages posted under a --key so later runs can
+// update them in place.
+type KeyedMessage struct {
+	ChannelID  string    `json:"channel_id"`
+	ThreadID   string    `json:"thread_id,omitempty"`
+	StarterID  string    `json:"starter_id,omitempty"`
+	MessageIDs []string  `json:"message_ids"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (c *CLI) keyStatePath() string {
+	return filepath.Join(c.stateDir(), "keys.json")
+}
+
+// deliver sends stdin to the configured channel, updating the messages of a
+// --key in place when one is set.
+func (c *CLI) deliver() (*Receipt, error) {
+	if c.key == "" {
+		return c.sendToDiscord()
+	}
+	return c.sendKeyed()
+}
+
+// sendKeyed edits the messages previously sent under the key, or sends new
+// ones if the key is unknown or was used for a different channel.
+func (c *CLI) sendKeyed() (*Receipt, error) {
+	if err := c.checkCredentials(); err != nil {
+		return nil, err
+	}
+	if len(c.stdinData) == 0 {
+		return nil, nil // Nothing to send
+	}
+
+	keys := make(map[string]KeyedMessage)
+	if err := loadState(c.keyStatePath(), &keys); err != nil {
+		return nil, err
+	}
+
+	entry, ok := keys[c.key]
+	if ok && entry.ChannelID != c.config.ChannelID {
+		if c.config.Debug {
+			log.Printf("Key %s was used for channel %s, posting new messages", c.key, entry.ChannelID)
+		}
+		ok = false
+	}
+
+	var receipt *Receipt
+	var err error
+	if ok {
+		receipt, err = c.updateKeyed(entry)
+	} else {
+		receipt, err = c.sendToDiscord()
+	}
+	if receipt == nil || len(receipt.MessageIDs) == 0 {
+		return receipt, err
+	}
+
+	// Remember whatever was sent, even on partial failure, so the next run
+	// edits those messages instead of duplicating them. Old messages not
+	// reached before the failure are kept too, so they are edited or
+	// deleted next time rather than left behind.
+	ids := receipt.MessageIDs
+	if ok && err != nil && len(entry.MessageIDs) > len(ids) {
+		ids = append(append([]string(nil), ids...), entry.MessageIDs[len(ids):]...)
+	}
+	saveErr := c.rememberKey(KeyedMessage{
+		ChannelID:  c.config.ChannelID,
+		ThreadID:   receipt.ThreadID,
+		StarterID:  receipt.StarterID,
+		MessageIDs: ids,
+		UpdatedAt:  time.Now().UTC(),
+	})
+	if saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return receipt, err
+}
+
+// rememberKey stores the messages of the key, reloading the file under the
+// lock so that concurrent sends for other keys are not lost.
+func (c *CLI) rememberKey(entry KeyedMessage) error {
+	unlock, err := lockState(c.keyStatePath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	keys := make(map[string]KeyedMessage)
+	if err := loadState(c.keyStatePath(), &keys); err != nil {
+		return err
+	}
+	keys[c.key] = entry
+	return saveState(c.keyStatePath(), keys)
+}
+
+// updateKeyed edits the stored messages to hold the new parts, posting extra
+// messages when the content grew and deleting leftovers when it shrank.
+func (c *CLI) updateKeyed(entry KeyedMessage) (*Receipt, error) {
+	discord, err := c.newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer discord.Close()
+
+	content, allowed, err := c.withMentions(discord, string(c.stdinData))
+	if err != nil {
+		return nil, err
+	}
+	parts := c.splitMessage(content)
+
+	target := entry.ChannelID
+	if entry.ThreadID != "" {
+		target = entry.ThreadID
+	}
+
+	if c.config.Debug {
+		log.Printf("Updating key %s: %d existing messages, %d parts", c.key, len(entry.MessageIDs), len(parts))
+	}
+
+	receipt := &Receipt{ChannelID: entry.ChannelID, ThreadID: entry.ThreadID, StarterID: entry.StarterID, Parts: len(parts)}
+	for i, part := range parts {
+		partAllowed := noMentions()
+		if i == 0 {
+			partAllowed = allowed
+		}
+
+		partContent, embeds := c.formatPart(i, part)
+		if i < len(entry.MessageIDs) {
+			// Always set embeds so that a level change replaces or clears them
+			if embeds == nil {
+				embeds = []*discordgo.MessageEmbed{}
+			}
+			edit := discordgo.NewMessageEdit(target, entry.MessageIDs[i]).SetContent(partContent).SetEmbeds(embeds)
+			edit.AllowedMentions = partAllowed
+			msg, err := discord.ChannelMessageEditComplex(edit)
+			if err == nil {
+				receipt.MessageIDs = append(receipt.MessageIDs, msg.ID)
+				continue
+			}
+			if !isNotFound(err) {
+				return receipt, fmt.Errorf("error editing message part %d/%d: %w", i+1, len(parts), err)
+			}
+			// The message was deleted by hand, post a replacement
+		}
+
+		msg, err := discord.ChannelMessageSendComplex(target, &discordgo.MessageSend{
+			Content:         partContent,
+			Embeds:          embeds,
+			AllowedMentions: partAllowed,
+		})
+		if err != nil {
+			return receipt, fmt.Errorf("error sending message part %d/%d: %w", i+1, len(parts), err)
+		}
+		receipt.MessageIDs = append(receipt.MessageIDs, msg.ID)
+	}
+
+	if len(entry.MessageIDs) > len(parts) {
+		for _, id := range entry.MessageIDs[len(parts):] {
+			if err := discord.ChannelMessageDelete(target, id); err != nil && !isNotFound(err) {
+				return receipt, fmt.Errorf("error deleting surplus message %s: %w", id, err)
+			}
+		}
+	}
+
+	return receipt, nil
+}
+
+// isNotFound reports whether err is a Discord 404 response.
+func isNotFound(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
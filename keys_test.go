@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"disgo/fakediscord"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestIsNotFound(t *testing.T) {
+	notFound := &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	forbidden := &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusForbidden}}
+
+	if !isNotFound(fmt.Errorf("wrapped: %w", notFound)) {
+		t.Error("Expected wrapped 404 to be detected")
+	}
+	if isNotFound(forbidden) {
+		t.Error("Expected 403 not to be treated as not found")
+	}
+	if isNotFound(fmt.Errorf("plain error")) {
+		t.Error("Expected plain error not to be treated as not found")
+	}
+}
+
+func TestKeyFlag(t *testing.T) {
+	cli := NewCLI()
+	if err := cli.parseFlags([]string{"--key", "deploy-prod"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if cli.key != "deploy-prod" {
+		t.Errorf("Expected key 'deploy-prod', got %s", cli.key)
+	}
+}
+
+// keyedSend sends content under the key "status" to srv, keeping state in
+// dir, and returns the stored message IDs.
+func keyedSend(t *testing.T, srv *fakediscord.Server, dir, content string) ([]string, error) {
+	t.Helper()
+	cli := fakeCLI(srv, content)
+	cli.configPath = dir
+	cli.key = "status"
+	cli.config.MaxMessageSize = 12
+	_, err := cli.sendKeyed()
+
+	keys := make(map[string]KeyedMessage)
+	if loadErr := loadState(cli.keyStatePath(), &keys); loadErr != nil {
+		t.Fatalf("Failed to load keys: %v", loadErr)
+	}
+	return keys["status"].MessageIDs, err
+}
+
+func channelContents(srv *fakediscord.Server) string {
+	var got []string
+	for _, m := range srv.Messages("100") {
+		got = append(got, strings.TrimSpace(m.Content))
+	}
+	return strings.Join(got, "|")
+}
+
+func TestSendKeyed(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{"same size", "alpha-one\nbravo-two", "alpha-uno\nbravo-dos", "alpha-uno|bravo-dos"},
+		{"grow", "alpha-one", "alpha-one\nbravo-two\ncharlie-3", "alpha-one|bravo-two|charlie-3"},
+		{"shrink", "alpha-one\nbravo-two\ncharlie-3", "alpha-one", "alpha-one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fakediscord.New()
+			defer srv.Close()
+			dir := t.TempDir()
+
+			first, err := keyedSend(t, srv, dir, tt.before)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			ids, err := keyedSend(t, srv, dir, tt.after)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := channelContents(srv); got != tt.want {
+				t.Errorf("Expected channel %q, got %q", tt.want, got)
+			}
+			if len(ids) != len(srv.Messages("100")) || ids[0] != first[0] {
+				t.Errorf("Expected stored IDs %v to match the channel and keep the first message %s", ids, first[0])
+			}
+		})
+	}
+}
+
+func TestSendKeyedPartialFailure(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	first, err := keyedSend(t, srv, dir, "alpha-one\nbravo-two\ncharlie-3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The second edit fails, leaving parts 2 and 3 untouched
+	srv.Inject(fakediscord.Fault{Method: "PATCH", Status: http.StatusForbidden, Code: 50013, Times: 1, After: 1})
+	ids, err := keyedSend(t, srv, dir, "alpha-uno\nbravo-dos\ncharlie-3")
+	if err == nil {
+		t.Fatal("Expected the failed edit to be reported")
+	}
+	if strings.Join(ids, ",") != strings.Join(first, ",") {
+		t.Fatalf("Expected all old IDs %v to be kept, got %v", first, ids)
+	}
+
+	ids, err = keyedSend(t, srv, dir, "alpha-uno\nbravo-dos\ncharlie-3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := channelContents(srv); got != "alpha-uno|bravo-dos|charlie-3" {
+		t.Errorf("Expected the retry to edit every old message, got %q", got)
+	}
+	if strings.Join(ids, ",") != strings.Join(first, ",") {
+		t.Errorf("Expected the same messages to be kept, got %v", ids)
+	}
+}
+
+func TestRememberKeyKeepsOtherKeys(t *testing.T) {
+	dir := t.TempDir()
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			cli := NewCLI()
+			cli.configPath = dir
+			cli.key = fmt.Sprintf("key-%d", i)
+			done <- cli.rememberKey(KeyedMessage{ChannelID: "100", MessageIDs: []string{fmt.Sprint(i)}})
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	keys := make(map[string]KeyedMessage)
+	cli := NewCLI()
+	cli.configPath = dir
+	if err := loadState(cli.keyStatePath(), &keys); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	if len(keys) != 8 {
+		t.Errorf("Expected 8 keys, got %d", len(keys))
+	}
+}
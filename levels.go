@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Severity levels, from least to most severe.
+const (
+	LevelDebug    = "debug"
+	LevelInfo     = "info"
+	LevelWarn     = "warn"
+	LevelError    = "error"
+	LevelCritical = "critical"
+)
+
+// levelOrder lists the levels from least to most severe.
+var levelOrder = []string{LevelDebug, LevelInfo, LevelWarn, LevelError, LevelCritical}
+
+// LevelConfig customizes how messages of one level are formatted and where
+// they are sent.
+type LevelConfig struct {
+	Emoji      string   `yaml:"emoji"`
+	Color      string   `yaml:"color"` // embed color as #rrggbb
+	ChannelID  string   `yaml:"channel_id"`
+	ThreadID   string   `yaml:"thread_id"`
+	ThreadName string   `yaml:"thread_name"`
+	Mentions   []string `yaml:"mentions"`
+	Silent     bool     `yaml:"silent"` // drop all other mentions for this level
+}
+
+// defaultLevels holds the built-in formatting for each level.
+var defaultLevels = map[string]LevelConfig{
+	LevelDebug:    {Emoji: "🐛", Color: "#95a5a6"},
+	LevelInfo:     {Emoji: "ℹ️", Color: "#3498db"},
+	LevelWarn:     {Emoji: "⚠️", Color: "#f1c40f"},
+	LevelError:    {Emoji: "❌", Color: "#e74c3c"},
+	LevelCritical: {Emoji: "🚨", Color: "#8e44ad"},
+}
+
+// parseLevel normalizes a level name, accepting common aliases.
+func parseLevel(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case LevelDebug, "trace":
+		return LevelDebug, nil
+	case LevelInfo, "notice":
+		return LevelInfo, nil
+	case LevelWarn, "warning":
+		return LevelWarn, nil
+	case LevelError, "err":
+		return LevelError, nil
+	case LevelCritical, "crit", "fatal":
+		return LevelCritical, nil
+	}
+	return "", configErrorf("invalid level %q (expected debug, info, warn, error or critical)", name)
+}
+
+// levelRank returns the position of a normalized level in levelOrder.
+func levelRank(level string) int {
+	for i, l := range levelOrder {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// levelConfig returns the configured settings for a level layered over the
+// built-in defaults.
+func (c *CLI) levelConfig(level string) LevelConfig {
+	lc := defaultLevels[level]
+	for name, custom := range c.config.Levels {
+		if normalized, err := parseLevel(name); err != nil || normalized != level {
+			continue
+		}
+		if custom.Emoji != "" {
+			lc.Emoji = custom.Emoji
+		}
+		if custom.Color != "" {
+			lc.Color = custom.Color
+		}
+		lc.ChannelID = custom.ChannelID
+		lc.ThreadID = custom.ThreadID
+		lc.ThreadName = custom.ThreadName
+		lc.Mentions = custom.Mentions
+		lc.Silent = custom.Silent
+	}
+	return lc
+}
+
+// parseColor parses an embed color written as #rrggbb.
+func parseColor(s string) (int, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || v > 0xffffff {
+		return 0, configErrorf("invalid color %q (expected #rrggbb)", s)
+	}
+	return int(v), nil
+}
+
+// belowMinLevel reports whether the message falls under the profile's
+// min_level. Messages sent without --level count as info.
+func (c *CLI) belowMinLevel() (bool, error) {
+	if c.config.MinLevel == "" {
+		return false, nil
+	}
+	min, err := parseLevel(c.config.MinLevel)
+	if err != nil {
+		return false, fmt.Errorf("min_level: %w", err)
+	}
+	level := LevelInfo
+	if c.level != "" {
+		if level, err = parseLevel(c.level); err != nil {
+			return false, err
+		}
+	}
+	return levelRank(level) < levelRank(min), nil
+}
+
+// applyLevel formats the message for its --level and applies the level's
+// target and mention overrides. Messages without a level are left alone.
+func (c *CLI) applyLevel() error {
+	if c.level == "" {
+		return nil
+	}
+	level, err := parseLevel(c.level)
+	if err != nil {
+		return err
+	}
+	lc := c.levelConfig(level)
+
+	if lc.ChannelID != "" {
+		c.config.ChannelID = lc.ChannelID
+	}
+	if lc.ThreadID != "" {
+		// As with routes, an existing thread is targeted by its ID
+		c.config.ChannelID = lc.ThreadID
+		c.config.ThreadName = ""
+	}
+	if lc.ThreadName != "" {
+		c.config.ThreadName = lc.ThreadName
+	}
+
+	if lc.Silent {
+		c.config.Mentions = nil
+		c.config.TagMentions = nil
+	}
+	c.config.Mentions = append(c.config.Mentions, lc.Mentions...)
+
+	if c.config.LevelEmbeds && lc.Color != "" {
+		if c.embedColor, err = parseColor(lc.Color); err != nil {
+			return fmt.Errorf("level %s: %w", level, err)
+		}
+		// Tracked apart from the color, as #000000 is a valid one
+		c.levelEmbed = true
+	}
+	if lc.Emoji != "" && len(c.stdinData) > 0 {
+		prefix := lc.Emoji + " "
+		if strings.HasPrefix(string(c.stdinData), fenceMarker) {
+			// A code block has to start on its own line
+			prefix = lc.Emoji + "\n"
+		}
+		c.stdinData = append([]byte(prefix), c.stdinData...)
+	}
+
+	if c.config.Debug {
+		log.Printf("Applied level %s", level)
+	}
+	return nil
+}
+
+// mentionLine matches the mention prefix line added by withMentions.
+var mentionLine = regexp.MustCompile(`^(?:(?:<@&?\d+>|@here|@everyone) ?)+$`)
+
+// formatPart returns the content and embeds for message part i. With level
+// embeds the text goes into a colored embed, while a leading mention line
+// stays in the content so that it still pings. Record embeds from
+// --format fields are attached to the first part.
+func (c *CLI) formatPart(i int, part string) (string, []*discordgo.MessageEmbed) {
+	content := part
+	var embeds []*discordgo.MessageEmbed
+	if c.levelEmbed {
+		content = ""
+		if first, rest, ok := strings.Cut(part, "\n"); ok && mentionLine.MatchString(first) {
+			content, part = first, rest
+		}
+		embeds = append(embeds, &discordgo.MessageEmbed{Description: part, Color: c.embedColor})
+	}
+	if i == 0 {
+		for _, e := range c.embeds {
+			if e.Color == 0 && c.levelEmbed {
+				e.Color = c.embedColor
+			}
+			embeds = append(embeds, e)
+		}
+	}
+	return content, embeds
+}
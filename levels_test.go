@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"warning", LevelWarn, false},
+		{" error ", LevelError, false},
+		{"fatal", LevelCritical, false},
+		{"loud", "", true},
+		{"", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := parseLevel(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBelowMinLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		minLevel string
+		level    string
+		expected bool
+	}{
+		{"no threshold", "", "debug", false},
+		{"below", "warn", "info", true},
+		{"equal", "warn", "warning", false},
+		{"above", "warn", "critical", false},
+		{"unleveled counts as info", "warn", "", true},
+		{"unleveled passes info", "info", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := NewCLI()
+			cli.config.MinLevel = tc.minLevel
+			cli.level = tc.level
+			got, err := cli.belowMinLevel()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	cli := NewCLI()
+	cli.config.MinLevel = "loud"
+	if _, err := cli.belowMinLevel(); err == nil {
+		t.Error("Expected an invalid min_level to be rejected")
+	}
+}
+
+func TestApplyLevel(t *testing.T) {
+	cli := NewCLI()
+	cli.config.ChannelID = "base"
+	cli.config.Mentions = []string{"role:111"}
+	cli.config.TagMentions = map[string][]string{"db": {"user:222"}}
+	cli.config.LevelEmbeds = true
+	cli.config.Levels = map[string]LevelConfig{
+		"critical": {Emoji: "🔥", ChannelID: "oncall", Mentions: []string{"here"}, Silent: true},
+		"debug":    {ThreadID: "debug-thread"},
+	}
+
+	cli.level = "fatal"
+	cli.stdinData = []byte("db down")
+	if err := cli.applyLevel(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(cli.stdinData) != "🔥 db down" {
+		t.Errorf("Expected custom emoji prefix, got %q", cli.stdinData)
+	}
+	if cli.config.ChannelID != "oncall" {
+		t.Errorf("Expected channel override, got %q", cli.config.ChannelID)
+	}
+	if len(cli.config.Mentions) != 1 || cli.config.Mentions[0] != "here" || cli.config.TagMentions != nil {
+		t.Errorf("Expected silent level to keep only its own mentions, got %v %v", cli.config.Mentions, cli.config.TagMentions)
+	}
+	if !cli.levelEmbed || cli.embedColor != 0x8e44ad {
+		t.Errorf("Expected default critical color, got %#x", cli.embedColor)
+	}
+
+	cli = NewCLI()
+	cli.config.ChannelID = "base"
+	cli.config.ThreadName = "ops"
+	cli.config.Levels = map[string]LevelConfig{"debug": {ThreadID: "debug-thread"}}
+	cli.level = "debug"
+	cli.stdinData = []byte("trace")
+	if err := cli.applyLevel(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cli.config.ChannelID != "debug-thread" || cli.config.ThreadName != "" {
+		t.Errorf("Expected existing thread as target, got channel %q thread name %q", cli.config.ChannelID, cli.config.ThreadName)
+	}
+	if cli.levelEmbed {
+		t.Errorf("Expected no embed without level_embeds, got %#x", cli.embedColor)
+	}
+	if !strings.HasPrefix(string(cli.stdinData), "🐛 ") {
+		t.Errorf("Expected default debug emoji, got %q", cli.stdinData)
+	}
+}
+
+func TestFormatPart(t *testing.T) {
+	cli := NewCLI()
+	content, embeds := cli.formatPart(0, "plain")
+	if content != "plain" || embeds != nil {
+		t.Errorf("Expected plain content without embeds, got %q %v", content, embeds)
+	}
+
+	cli.levelEmbed, cli.embedColor = true, 0xe74c3c
+	content, embeds = cli.formatPart(0, "<@123> <@&456> @here\n❌ failed")
+	if content != "<@123> <@&456> @here" {
+		t.Errorf("Expected mentions to stay in content, got %q", content)
+	}
+	if len(embeds) != 1 || embeds[0].Description != "❌ failed" || embeds[0].Color != 0xe74c3c {
+		t.Errorf("Expected colored embed with the message, got %+v", embeds)
+	}
+
+	content, embeds = cli.formatPart(1, "first line\nsecond")
+	if content != "" || embeds[0].Description != "first line\nsecond" {
+		t.Errorf("Expected the whole part in the embed, got %q %+v", content, embeds[0])
+	}
+}
+
+func TestApplyLevelBlackEmbed(t *testing.T) {
+	cli := NewCLI()
+	cli.config.LevelEmbeds = true
+	cli.config.Levels = map[string]LevelConfig{"info": {Color: "#000000"}}
+	cli.level = "info"
+	cli.stdinData = []byte("all quiet")
+	if err := cli.applyLevel(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, embeds := cli.formatPart(0, string(cli.stdinData))
+	if content != "" || len(embeds) != 1 || embeds[0].Color != 0 {
+		t.Errorf("Expected a black level embed, got %q %+v", content, embeds)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// messageEnv returns the environment passed to --exec handlers.
+func messageEnv(m *discordgo.Message) []string {
+	hm := newHistoryMessage(m)
+	return []string{
+		"DISGO_MESSAGE_ID=" + hm.ID,
+		"DISGO_CHANNEL_ID=" + hm.ChannelID,
+		"DISGO_GUILD_ID=" + m.GuildID,
+		"DISGO_AUTHOR_ID=" + hm.AuthorID,
+		"DISGO_AUTHOR=" + hm.Author,
+		"DISGO_TIMESTAMP=" + hm.Timestamp.Format(time.RFC3339),
+		"DISGO_CONTENT=" + hm.Content,
+	}
+}
+
+// DefaultExecTimeout bounds how long a listen --exec handler may run.
+const DefaultExecTimeout = time.Minute
+
+// listenQueueSize is how many messages may wait for the handler before new
+// ones are dropped.
+const listenQueueSize = 100
+
+// listener streams messages from one channel and optionally hands each one
+// to a command whose output is sent back as a reply. Messages are queued in
+// the order the gateway delivers them and handled one at a time by work.
+type listener struct {
+	cli       *CLI
+	ctx       context.Context
+	channelID string
+	filter    historyFilter
+	queue     chan *discordgo.Message
+}
+
+// handle queues matching messages. It runs on the gateway's event loop, so
+// it never waits for the handler: a full queue drops the message instead.
+func (l *listener) handle(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.ChannelID != l.channelID {
+		return
+	}
+	// Never react to our own messages, or replies would loop forever
+	if m.Author == nil || (s.State.User != nil && m.Author.ID == s.State.User.ID) {
+		return
+	}
+	if !l.filter.match(m.Message) {
+		return
+	}
+
+	select {
+	case l.queue <- m.Message:
+	default:
+		fmt.Fprintf(os.Stderr, "Dropped message %s: %d messages are already waiting for the handler\n", m.ID, cap(l.queue))
+	}
+}
+
+// work prints and handles queued messages in order until the context ends.
+func (l *listener) work(s *discordgo.Session) {
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case m := <-l.queue:
+			l.process(s, m)
+		}
+	}
+}
+
+// process prints a message as an NDJSON line and replies with the output
+// of --exec, if set.
+func (l *listener) process(s *discordgo.Session, m *discordgo.Message) {
+	data, err := json.Marshal(newHistoryMessage(m))
+	if err == nil {
+		fmt.Fprintln(os.Stdout, string(data))
+	}
+
+	if l.cli.exec == "" {
+		return
+	}
+	reply, err := l.runHandler(m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Handler failed for message %s: %v\n", m.ID, err)
+	}
+	if len(bytes.TrimSpace(reply)) == 0 || l.ctx.Err() != nil {
+		return
+	}
+	if err := l.reply(s, m, string(reply)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error replying to message %s: %v\n", m.ID, err)
+	}
+}
+
+// runHandler runs --exec with the message content on stdin and its metadata
+// in DISGO_* environment variables, returning what it wrote to stdout. The
+// handler is killed after --exec-timeout so one hung run cannot stall the
+// queue.
+func (l *listener) runHandler(m *discordgo.Message) ([]byte, error) {
+	timeout := l.cli.execTimeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(l.ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", l.cli.exec)
+	cmd.Env = append(os.Environ(), messageEnv(m)...)
+	cmd.Stdin = bytes.NewBufferString(m.Content)
+	cmd.Stderr = os.Stderr
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	// Background children of the handler may hold stdout open after it
+	// is killed
+	cmd.WaitDelay = time.Second
+
+	if l.cli.config.Debug {
+		log.Printf("Running handler for message %s", m.ID)
+	}
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out.Bytes(), fmt.Errorf("timed out after %s", timeout)
+	}
+	return out.Bytes(), err
+}
+
+// reply sends the handler output as a reply, split like any other message.
+func (l *listener) reply(s *discordgo.Session, m *discordgo.Message, content string) error {
+	parts := l.cli.splitMessage(content)
+	for i, part := range parts {
+		data := &discordgo.MessageSend{
+			Content:         part,
+			AllowedMentions: noMentions(),
+		}
+		if i == 0 {
+			data.Reference = m.Reference()
+		}
+		if _, err := s.ChannelMessageSendComplex(m.ChannelID, data); err != nil {
+			return fmt.Errorf("error sending reply part %d/%d: %w", i+1, len(parts), err)
+		}
+	}
+	return nil
+}
+
+// listenContext ends listening on SIGINT or SIGTERM, or once --timeout has
+// passed.
+func (c *CLI) listenContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if c.timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// runListen prints each new message in the channel as an NDJSON line until
+// interrupted, or for --timeout when given.
+func (c *CLI) runListen() error {
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+
+	ctx, stop := c.listenContext()
+	defer stop()
+
+	l := &listener{
+		cli:       c,
+		ctx:       ctx,
+		channelID: c.config.ChannelID,
+		filter:    historyFilter{author: c.author},
+		queue:     make(chan *discordgo.Message, listenQueueSize),
+	}
+	// As with read, --thread names the thread ID to listen to
+	if c.threadName != "" {
+		l.channelID = c.threadName
+	}
+	if c.match != "" {
+		var err error
+		l.filter.pattern, err = regexp.Compile(c.match)
+		if err != nil {
+			return configErrorf("invalid --match pattern: %w", err)
+		}
+	}
+
+	discord, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	// Events are dispatched in the order they arrive rather than each on
+	// its own goroutine, so the queue keeps the channel's order
+	discord.SyncEvents = true
+	discord.AddHandler(l.handle)
+	// Message content is a privileged intent and must be enabled for the
+	// bot in the Discord developer portal
+	intents := discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentMessageContent
+	if err := c.openGateway(discord, intents); err != nil {
+		return err
+	}
+
+	if c.config.Debug {
+		log.Printf("Listening to channel %s", l.channelID)
+	}
+	l.work(discord)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMessageEnv(t *testing.T) {
+	env := messageEnv(&discordgo.Message{
+		ID:        "1",
+		ChannelID: "2",
+		Content:   "!status",
+		Author:    &discordgo.User{ID: "42", Username: "alice"},
+	})
+
+	want := map[string]bool{
+		"DISGO_MESSAGE_ID=1":    true,
+		"DISGO_CHANNEL_ID=2":    true,
+		"DISGO_AUTHOR=alice":    true,
+		"DISGO_CONTENT=!status": true,
+		"DISGO_AUTHOR_ID=42":    true,
+	}
+	for _, e := range env {
+		delete(want, e)
+	}
+	if len(want) != 0 {
+		t.Errorf("Missing environment entries: %v", want)
+	}
+}
+
+func TestListenerRunHandler(t *testing.T) {
+	cli := NewCLI()
+	cli.exec = `printf '%s says %s' "$DISGO_AUTHOR" "$(cat)"`
+	l := &listener{cli: cli, ctx: context.Background()}
+
+	out, err := l.runHandler(&discordgo.Message{
+		ID:      "1",
+		Content: "hello",
+		Author:  &discordgo.User{ID: "42", Username: "alice"},
+	})
+	if err != nil {
+		t.Fatalf("Handler failed: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "alice says hello" {
+		t.Errorf("Unexpected handler output %q", out)
+	}
+}
+
+func TestListenerHandlerTimeout(t *testing.T) {
+	cli := NewCLI()
+	cli.exec = "echo partial; exec sleep 5"
+	cli.execTimeout = 100 * time.Millisecond
+	l := &listener{cli: cli, ctx: context.Background()}
+
+	start := time.Now()
+	out, err := l.runHandler(&discordgo.Message{ID: "1", Author: &discordgo.User{ID: "42"}})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the handler to be killed, took %s", elapsed)
+	}
+	if strings.TrimSpace(string(out)) != "partial" {
+		t.Errorf("Expected the output before the timeout, got %q", out)
+	}
+}
+
+func TestListenerQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := &listener{cli: NewCLI(), ctx: ctx, channelID: "2", queue: make(chan *discordgo.Message, 2)}
+	s := &discordgo.Session{State: discordgo.NewState()}
+
+	for _, id := range []string{"1", "2", "3"} {
+		l.handle(s, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID:        id,
+			ChannelID: "2",
+			Author:    &discordgo.User{ID: "42"},
+		}})
+	}
+	if len(l.queue) != 2 {
+		t.Fatalf("Expected the third message to be dropped from a full queue, got %d queued", len(l.queue))
+	}
+	for _, want := range []string{"1", "2"} {
+		if m := <-l.queue; m.ID != want {
+			t.Errorf("Expected message %s next, got %s", want, m.ID)
+		}
+	}
+}
+
+func TestListenContextTimeout(t *testing.T) {
+	cli := NewCLI()
+	ctx, stop := cli.listenContext()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("Expected no deadline without --timeout")
+	}
+	stop()
+
+	cli.timeout = 50 * time.Millisecond
+	ctx, stop = cli.listenContext()
+	defer stop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected listening to stop after --timeout")
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Input formats converted to Discord markdown.
+const (
+	InputHTML = "html"
+	InputGFM  = "gfm"
+)
+
+// markdownEscaper escapes characters Discord would treat as formatting in
+// text taken from HTML.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `|`, `\|`)
+
+// droppedElements are removed together with their content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Iframe: true, atom.Object: true, atom.Svg: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Textarea: true,
+	atom.Input: true,
+}
+
+// blockElements start and end a paragraph.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Main: true, atom.Nav: true,
+	atom.Aside: true, atom.Figure: true, atom.Figcaption: true, atom.Center: true,
+	atom.Address: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Details: true, atom.Summary: true, atom.Hr: true,
+}
+
+// htmlConverter renders an HTML tree as Discord markdown, collecting image
+// URLs to be sent as embeds.
+type htmlConverter struct {
+	images    []string
+	listDepth int
+}
+
+// convertHTML converts an HTML document or fragment to Discord markdown.
+func convertHTML(content string) (string, []string, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing HTML: %w", err)
+	}
+	conv := &htmlConverter{}
+	return tidyMarkdown(conv.render(doc)), conv.images, nil
+}
+
+func (h *htmlConverter) children(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(h.render(child))
+	}
+	return b.String()
+}
+
+func (h *htmlConverter) render(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return markdownEscaper.Replace(collapseSpace(n.Data))
+	case html.DocumentNode:
+		return h.children(n)
+	case html.ElementNode:
+	default:
+		return "" // comments and doctypes
+	}
+	if droppedElements[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.H1, atom.H2, atom.H3:
+		level := int(n.Data[1] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + oneLine(h.children(n)) + "\n\n"
+	case atom.H4, atom.H5, atom.H6:
+		// Discord only has three heading levels
+		return "\n\n" + wrapInline("**", oneLine(h.children(n))) + "\n\n"
+	case atom.Strong, atom.B:
+		return wrapInline("**", h.children(n))
+	case atom.Em, atom.I:
+		return wrapInline("*", h.children(n))
+	case atom.U, atom.Ins:
+		return wrapInline("__", h.children(n))
+	case atom.S, atom.Del, atom.Strike:
+		return wrapInline("~~", h.children(n))
+	case atom.Code, atom.Kbd, atom.Samp:
+		return inlineCode(textContent(n))
+	case atom.Pre:
+		return "\n\n" + wrapCode(textContent(n), preLanguage(n)) + "\n\n"
+	case atom.A:
+		return h.link(n)
+	case atom.Img:
+		if src := attr(n, "src"); strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "http://") {
+			h.images = append(h.images, src)
+		}
+		return ""
+	case atom.Ul, atom.Ol:
+		return h.list(n)
+	case atom.Blockquote:
+		inner := strings.TrimSpace(tidyMarkdown(h.children(n)))
+		return "\n\n" + quoteLines(inner) + "\n\n"
+	case atom.Table:
+		return "\n\n" + h.table(n) + "\n\n"
+	}
+
+	if blockElements[n.DataAtom] {
+		return "\n\n" + h.children(n) + "\n\n"
+	}
+	return h.children(n)
+}
+
+// link renders an anchor as a masked link, or the bare URL when the text is
+// the URL itself.
+func (h *htmlConverter) link(n *html.Node) string {
+	text := strings.TrimSpace(h.children(n))
+	href := attr(n, "href")
+	if !strings.HasPrefix(href, "https://") && !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "mailto:") {
+		return text
+	}
+	if text == "" || text == markdownEscaper.Replace(href) {
+		return href
+	}
+	return "[" + oneLine(text) + "](" + href + ")"
+}
+
+// list renders ul and ol items. Discord shows two levels of nesting, so
+// deeper lists are kept at the second level.
+func (h *htmlConverter) list(n *html.Node) string {
+	indent := ""
+	if h.listDepth > 0 {
+		indent = "  "
+	}
+	h.listDepth++
+	defer func() { h.listDepth-- }()
+
+	start := 1
+	if v, err := strconv.Atoi(attr(n, "start")); err == nil {
+		start = v
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	item := 0
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(start+item) + ". "
+		}
+		item++
+
+		lines := strings.Split(strings.TrimSpace(tidyMarkdown(h.children(child))), "\n")
+		b.WriteString(indent + marker + lines[0] + "\n")
+		for _, line := range lines[1:] {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			// Nested list lines are already indented
+			if !strings.HasPrefix(line, "  ") {
+				line = indent + "  " + line
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	if h.listDepth == 1 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// table renders an HTML table as a code-block table.
+func (h *htmlConverter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Th || cell.DataAtom == atom.Td) {
+						row = append(row, strings.TrimSpace(collapseSpace(textContent(cell))))
+					}
+				}
+				rows = append(rows, row)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+	return wrapCode(renderTable(&records{headers: rows[0], rows: rows[1:]}, DefaultMaxColumnWidth), "")
+}
+
+// textContent returns the raw text below n.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// preLanguage reads the language from a language-xxx class on pre or its
+// code element.
+func preLanguage(n *html.Node) string {
+	for _, node := range []*html.Node{n, n.FirstChild} {
+		if node == nil || node.Type != html.ElementNode {
+			continue
+		}
+		for _, class := range strings.Fields(attr(node, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+var spaceRun = regexp.MustCompile(`\s+`)
+
+// collapseSpace folds whitespace runs into a single space like a browser.
+func collapseSpace(s string) string {
+	return spaceRun.ReplaceAllString(s, " ")
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// wrapInline surrounds text with a markdown marker, keeping surrounding
+// spaces outside so the marker stays valid.
+func wrapInline(marker, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// inlineCode renders text as inline code, using a longer delimiter when the
+// text itself contains backticks.
+func inlineCode(text string) string {
+	text = oneLine(text)
+	if text == "" {
+		return ""
+	}
+	if strings.Contains(text, "`") {
+		return "`` " + text + " ``"
+	}
+	return "`" + text + "`"
+}
+
+func quoteLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidyMarkdown trims trailing spaces and collapses runs of blank lines,
+// leaving code blocks untouched.
+func tidyMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), fenceMarker) {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(line, "  ") && listItem.MatchString(trimmed) {
+			// Keep the indentation of nested list items
+			trimmed = "  " + trimmed
+		}
+		lines[i] = trimmed
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+var (
+	listItem        = regexp.MustCompile(`^([-*+]|\d+[.)]) `)
+	gfmHeading      = regexp.MustCompile(`^#{4,6}\s+(.*?)(\s+#+)?\s*$`)
+	gfmImage        = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	gfmEmptyLink    = regexp.MustCompile(`\[\s*\]\(\s*<?([^)\s>]+)>?\s*\)`)
+	gfmTaskItem     = regexp.MustCompile(`^(\s*[-*+]\s+)\[([ xX])\]\s`)
+	gfmNestedItem   = regexp.MustCompile(`^\s{2,}([-*+]|\d+[.)])\s+`)
+	gfmRule         = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	gfmSetext       = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	gfmTableDivider = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	gfmComment      = regexp.MustCompile(`(?s)<!--.*?-->`)
+	gfmBreak        = regexp.MustCompile(`(?i)<br\s*/?>`)
+	gfmTag          = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+// convertGFM converts GitHub-flavored markdown to what Discord renders:
+// deep headings become bold lines, tables become code-block tables, images
+// are pulled out for embeds and raw HTML tags are dropped.
+func convertGFM(content string) (string, []string) {
+	content = gfmComment.ReplaceAllString(strings.ReplaceAll(content, "\r\n", "\n"), "")
+	lines := strings.Split(content, "\n")
+
+	var images []string
+	var out []string
+	inFence := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, fenceMarker) || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			out = append(out, strings.Replace(line, "~~~", fenceMarker, 1))
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+
+		// Tables: a header row followed by a divider row
+		if strings.Contains(line, "|") && i+1 < len(lines) && gfmTableDivider.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-") {
+			rec := &records{headers: tableCells(line)}
+			i += 2
+			for ; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				rec.rows = append(rec.rows, tableCells(lines[i]))
+			}
+			i--
+			out = append(out, wrapCode(renderTable(rec, DefaultMaxColumnWidth), ""))
+			continue
+		}
+
+		// Setext headings underline the previous line
+		if m := gfmSetext.FindStringSubmatch(line); m != nil && len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" && !listItem.MatchString(strings.TrimSpace(out[len(out)-1])) {
+			prefix := "## "
+			if m[1][0] == '=' {
+				prefix = "# "
+			}
+			out[len(out)-1] = prefix + strings.TrimSpace(out[len(out)-1])
+			continue
+		}
+		if gfmRule.MatchString(line) {
+			out = append(out, "")
+			continue
+		}
+
+		if m := gfmHeading.FindStringSubmatch(line); m != nil {
+			line = "**" + m[1] + "**"
+		}
+		if m := gfmTaskItem.FindStringSubmatch(line); m != nil {
+			box := "☐"
+			if m[2] != " " {
+				box = "☑"
+			}
+			line = m[1] + box + " " + line[len(m[0]):]
+		}
+		if m := gfmNestedItem.FindStringSubmatch(line); m != nil {
+			// Discord shows two levels of nesting
+			line = "  " + m[1] + " " + line[len(m[0]):]
+		}
+
+		line = mapOutsideCode(line, func(s string) string {
+			for _, m := range gfmImage.FindAllStringSubmatch(s, -1) {
+				images = append(images, m[1])
+			}
+			s = gfmImage.ReplaceAllString(s, "")
+			s = gfmEmptyLink.ReplaceAllString(s, "$1")
+			s = gfmBreak.ReplaceAllString(s, "\n")
+			return gfmTag.ReplaceAllString(s, "")
+		})
+		out = append(out, line)
+	}
+	return tidyGFM(strings.Join(out, "\n")), images
+}
+
+// tableCells splits a pipe table row into trimmed cells.
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// mapOutsideCode applies fn to the parts of a line outside inline code spans.
+func mapOutsideCode(line string, fn func(string) string) string {
+	segments := strings.Split(line, "`")
+	for i := 0; i < len(segments); i += 2 {
+		segments[i] = fn(segments[i])
+	}
+	return strings.Join(segments, "`")
+}
+
+// tidyGFM collapses the blank lines left behind by removed constructs.
+func tidyGFM(s string) string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
+
+// convertInput converts --input-format content to Discord markdown. Images
+// become embeds on the first part; those beyond the embed limit are listed
+// as links at the end.
+func (c *CLI) convertInput(content string) (string, error) {
+	var images []string
+	switch c.config.InputFormat {
+	case InputHTML:
+		var err error
+		if content, images, err = convertHTML(content); err != nil {
+			return "", err
+		}
+	case InputGFM:
+		content, images = convertGFM(content)
+	default:
+		return "", configErrorf("invalid input format %q (expected html or gfm)", c.config.InputFormat)
+	}
+
+	var extra []string
+	for _, url := range images {
+		if len(c.embeds) < maxEmbedsPerMessage-1 {
+			c.embeds = append(c.embeds, &discordgo.MessageEmbed{Image: &discordgo.MessageEmbedImage{URL: url}})
+		} else {
+			extra = append(extra, url)
+		}
+	}
+	if len(extra) > 0 {
+		content += "\n\n" + strings.Join(extra, "\n")
+	}
+	if strings.TrimSpace(content) == "" && len(images) > 0 {
+		// Embeds can't be sent without a message to carry them
+		content = fmt.Sprintf("🖼️ %d image%s", len(images), plural(len(images)))
+	}
+	return content, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"paragraphs", "<p>Hello <b>world</b></p><p>Second <i>line</i></p>", "Hello **world**\n\nSecond *line*"},
+		{"headings", "<h1>Title</h1><h2>Sub</h2><h5>Deep</h5>", "# Title\n\n## Sub\n\n**Deep**"},
+		{"line break", "one<br>two", "one\ntwo"},
+		{"link", `<a href="https://example.com">site</a> and <a href="https://x.io">https://x.io</a>`, "[site](https://example.com) and https://x.io"},
+		{"unsafe link keeps text", `<a href="javascript:alert(1)">click</a>`, "click"},
+		{"escaping", "<p>2*3 = 6_ok</p>", `2\*3 = 6\_ok`},
+		{"inline code", "run <code>go test</code>", "run `go test`"},
+		{"pre", `<pre><code class="language-go">x := 1
+y := 2</code></pre>`, "```go\nx := 1\ny := 2\n```"},
+		{"dropped", "<style>p{}</style><script>alert(1)</script><p>kept</p><!-- note -->", "kept"},
+		{"blockquote", "<blockquote><p>quoted</p><p>more</p></blockquote>", "> quoted\n>\n> more"},
+		{"lists", "<ul><li>a<ul><li>b<ul><li>c</li></ul></li></ul></li><li>d</li></ul><ol start=\"3\"><li>x</li></ol>",
+			"- a\n  - b\n  - c\n- d\n\n3. x"},
+		{"table", "<table><tr><th>name</th><th>n</th></tr><tr><td>a</td><td>1</td></tr></table>",
+			"```\nname  n\n----  -\na     1\n```"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := convertHTML(tc.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestConvertHTMLImages(t *testing.T) {
+	got, images, err := convertHTML(`<p>Chart:</p><img src="https://example.com/a.png" alt="a"><img src="data:image/png;base64,xx">`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "Chart:" {
+		t.Errorf("Expected images removed from the text, got %q", got)
+	}
+	if !reflect.DeepEqual(images, []string{"https://example.com/a.png"}) {
+		t.Errorf("Expected only the http image, got %v", images)
+	}
+}
+
+func TestConvertGFM(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"supported markdown unchanged", "# Title\n\n**bold** and [link](https://x.io)", "# Title\n\n**bold** and [link](https://x.io)"},
+		{"deep heading", "#### Details ####", "**Details**"},
+		{"setext", "Title\n=====\n\nSub\n---", "# Title\n\n## Sub"},
+		{"rule", "above\n\n***\n\nbelow", "above\n\nbelow"},
+		{"task list", "- [ ] todo\n- [x] done", "- ☐ todo\n- ☑ done"},
+		{"nested list", "- a\n    - b\n        - c", "- a\n  - b\n  - c"},
+		{"html tags", "line<br>next <details><summary>More</summary>hidden</details>", "line\nnext Morehidden"},
+		{"inline code keeps tags", "use `<br>` here", "use `<br>` here"},
+		{"comment", "a<!-- hidden\ncomment -->b", "ab"},
+		{"fence untouched", "~~~\n#### not a heading\n| a | b |\n~~~", "```\n#### not a heading\n| a | b |\n```"},
+		{"table", "| name | count |\n|------|------:|\n| a \\| b | 1 |\n| c | 22 |\n\nafter",
+			"```\nname   count\n-----  -----\na | b      1\nc         22\n```\n\nafter"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _ := convertGFM(tc.input)
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestConvertGFMImages(t *testing.T) {
+	got, images := convertGFM(`See ![chart](https://x.io/c.png "Chart") and [![badge](https://x.io/b.svg)](https://x.io/ci)`)
+	if got != "See  and https://x.io/ci" {
+		t.Errorf("Expected images removed and the badge link kept, got %q", got)
+	}
+	if !reflect.DeepEqual(images, []string{"https://x.io/c.png", "https://x.io/b.svg"}) {
+		t.Errorf("Expected both images, got %v", images)
+	}
+}
+
+func TestConvertInput(t *testing.T) {
+	cli := NewCLI()
+	cli.config.InputFormat = InputGFM
+
+	var b strings.Builder
+	for i := 0; i < maxEmbedsPerMessage+1; i++ {
+		b.WriteString("![](https://x.io/" + string(rune('a'+i)) + ".png)\n")
+	}
+	got, err := cli.convertInput(b.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cli.embeds) != maxEmbedsPerMessage-1 {
+		t.Errorf("Expected %d image embeds, got %d", maxEmbedsPerMessage-1, len(cli.embeds))
+	}
+	if cli.embeds[0].Image == nil || cli.embeds[0].Image.URL != "https://x.io/a.png" {
+		t.Errorf("Expected the first image as an embed, got %+v", cli.embeds[0])
+	}
+	if !strings.HasSuffix(got, "https://x.io/j.png\nhttps://x.io/k.png") {
+		t.Errorf("Expected overflow images as links, got %q", got)
+	}
+
+	cli.config.InputFormat = "rtf"
+	if _, err := cli.convertInput("x"); err == nil {
+		t.Error("Expected an invalid input format to be rejected")
+	}
+}
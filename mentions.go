@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Mention kinds accepted by --mention and the mentions config.
+const (
+	MentionUser     = "user"
+	MentionRole     = "role"
+	MentionHere     = "here"
+	MentionEveryone = "everyone"
+)
+
+// Mention is a single ping target. Value holds an ID or a name that is
+// resolved through the guild given by ServerID.
+type Mention struct {
+	Kind  string
+	Value string
+}
+
+// String returns the mention in the form parseMention accepts.
+func (m Mention) String() string {
+	if m.Value == "" {
+		return m.Kind
+	}
+	return m.Kind + ":" + m.Value
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseMention parses user:ID, role:ID, here or everyone.
+func parseMention(spec string) (Mention, error) {
+	spec = strings.TrimSpace(spec)
+	switch strings.ToLower(spec) {
+	case MentionHere, "@here":
+		return Mention{Kind: MentionHere}, nil
+	case MentionEveryone, "@everyone":
+		return Mention{Kind: MentionEveryone}, nil
+	}
+
+	kind, value, ok := strings.Cut(spec, ":")
+	if !ok || strings.TrimSpace(value) == "" {
+		return Mention{}, configErrorf("invalid mention %q (expected user:ID, role:ID or here)", spec)
+	}
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind != MentionUser && kind != MentionRole {
+		return Mention{}, configErrorf("invalid mention kind %q (expected user or role)", kind)
+	}
+	return Mention{Kind: kind, Value: strings.TrimSpace(value)}, nil
+}
+
+// collectMentions gathers the configured mentions plus those mapped from the
+// message tags, dropping duplicates.
+func (c *CLI) collectMentions() ([]Mention, error) {
+	specs := append([]string(nil), c.config.Mentions...)
+	for _, tag := range c.config.Tags {
+		specs = append(specs, c.config.TagMentions[tag]...)
+	}
+
+	seen := make(map[Mention]bool)
+	var mentions []Mention
+	for _, spec := range specs {
+		m, err := parseMention(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		mentions = append(mentions, m)
+	}
+	return mentions, nil
+}
+
+// isSnowflake reports whether s looks like a Discord ID.
+func isSnowflake(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// noMentions returns an allowed-mentions object that suppresses every ping.
+func noMentions() *discordgo.MessageAllowedMentions {
+	return &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}}
+}
+
+// resolveMentions turns mentions into the text prefix that pings them and the
+// allowed-mentions object that permits exactly those pings. Names are looked
+// up in the guild given by guildID.
+func resolveMentions(discord DiscordClient, guildID string, mentions []Mention) (string, *discordgo.MessageAllowedMentions, error) {
+	allowed := noMentions()
+	if len(mentions) == 0 {
+		return "", allowed, nil
+	}
+
+	var roles []*discordgo.Role
+	var parts []string
+	for _, m := range mentions {
+		switch m.Kind {
+		case MentionHere, MentionEveryone:
+			parts = append(parts, "@"+m.Kind)
+			if len(allowed.Parse) == 0 {
+				allowed.Parse = append(allowed.Parse, discordgo.AllowedMentionTypeEveryone)
+			}
+		case MentionUser:
+			id := m.Value
+			if !isSnowflake(id) {
+				if guildID == "" {
+					return "", nil, configErrorf("cannot resolve user %q: server ID not configured", m.Value)
+				}
+				members, err := discord.GuildMembersSearch(guildID, m.Value, 10)
+				if err != nil {
+					return "", nil, fmt.Errorf("error resolving user %q: %w", m.Value, err)
+				}
+				id = matchMember(members, m.Value)
+				if id == "" {
+					return "", nil, fmt.Errorf("user %q not found in server %s", m.Value, guildID)
+				}
+			}
+			parts = append(parts, "<@"+id+">")
+			allowed.Users = append(allowed.Users, id)
+		case MentionRole:
+			id := m.Value
+			if !isSnowflake(id) {
+				if guildID == "" {
+					return "", nil, configErrorf("cannot resolve role %q: server ID not configured", m.Value)
+				}
+				if roles == nil {
+					var err error
+					roles, err = discord.GuildRoles(guildID)
+					if err != nil {
+						return "", nil, fmt.Errorf("error fetching roles: %w", err)
+					}
+				}
+				id = matchRole(roles, m.Value)
+				if id == "" {
+					return "", nil, fmt.Errorf("role %q not found in server %s", m.Value, guildID)
+				}
+			}
+			parts = append(parts, "<@&"+id+">")
+			allowed.Roles = append(allowed.Roles, id)
+		}
+	}
+	return strings.Join(parts, " "), allowed, nil
+}
+
+func matchMember(members []*discordgo.Member, name string) string {
+	for _, m := range members {
+		if m.User == nil {
+			continue
+		}
+		if strings.EqualFold(m.User.Username, name) || strings.EqualFold(m.Nick, name) || strings.EqualFold(m.User.GlobalName, name) {
+			return m.User.ID
+		}
+	}
+	return ""
+}
+
+func matchRole(roles []*discordgo.Role, name string) string {
+	name = strings.TrimPrefix(name, "@")
+	for _, r := range roles {
+		if strings.EqualFold(r.Name, name) {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+// withMentions prefixes content with the configured mentions and returns the
+// allowed-mentions object for the part carrying them.
+func (c *CLI) withMentions(discord DiscordClient, content string) (string, *discordgo.MessageAllowedMentions, error) {
+	mentions, err := c.collectMentions()
+	if err != nil {
+		return "", nil, err
+	}
+	prefix, allowed, err := resolveMentions(discord, c.config.ServerID, mentions)
+	if err != nil {
+		return "", nil, err
+	}
+	if prefix != "" {
+		content = prefix + "\n" + content
+	}
+	return content, allowed, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseMention(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected Mention
+		wantErr  bool
+	}{
+		{spec: "user:123", expected: Mention{Kind: MentionUser, Value: "123"}},
+		{spec: "role:oncall", expected: Mention{Kind: MentionRole, Value: "oncall"}},
+		{spec: "here", expected: Mention{Kind: MentionHere}},
+		{spec: "@everyone", expected: Mention{Kind: MentionEveryone}},
+		{spec: "channel:1", wantErr: true},
+		{spec: "user:", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			m, err := parseMention(tc.spec)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tc.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if m != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, m)
+			}
+		})
+	}
+}
+
+func TestCollectMentionsFromTags(t *testing.T) {
+	cli := NewCLI()
+	cli.config = Config{
+		Tags:        []string{"critical", "app"},
+		Mentions:    []string{"user:42"},
+		TagMentions: map[string][]string{"critical": {"role:99", "user:42"}},
+	}
+
+	mentions, err := cli.collectMentions()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(mentions) != 2 {
+		t.Fatalf("Expected 2 deduplicated mentions, got %v", mentions)
+	}
+}
+
+func TestResolveMentionsByID(t *testing.T) {
+	prefix, allowed, err := resolveMentions(nil, "", []Mention{
+		{Kind: MentionUser, Value: "42"},
+		{Kind: MentionRole, Value: "99"},
+		{Kind: MentionHere},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prefix != "<@42> <@&99> @here" {
+		t.Errorf("Unexpected prefix %q", prefix)
+	}
+	if len(allowed.Users) != 1 || len(allowed.Roles) != 1 || len(allowed.Parse) != 1 {
+		t.Errorf("Unexpected allowed mentions %+v", allowed)
+	}
+}
+
+func TestNoMentionsByDefault(t *testing.T) {
+	prefix, allowed, err := resolveMentions(nil, "", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prefix != "" {
+		t.Errorf("Expected empty prefix, got %q", prefix)
+	}
+	if allowed == nil || allowed.Parse == nil || len(allowed.Parse) != 0 {
+		t.Errorf("Expected explicit empty parse list, got %+v", allowed)
+	}
+
+	if _, _, err := resolveMentions(nil, "", []Mention{{Kind: MentionRole, Value: "oncall"}}); err == nil {
+		t.Error("Expected error resolving a role name without a server ID")
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Output formats for receipts.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Receipt records where a send went, the IDs of the messages it created and
+// how it went. Parts counts the parts the content was split into; parts that
+// weren't delivered, including those never attempted after a failure, count
+// as failed.
+type Receipt struct {
+	Route       string    `json:"route,omitempty"`
+	ChannelID   string    `json:"channel_id"`
+	ThreadID    string    `json:"thread_id,omitempty"`
+	StarterID   string    `json:"starter_id,omitempty"`
+	MessageIDs  []string  `json:"message_ids"`
+	Parts       int       `json:"parts"`
+	PartsSent   int       `json:"parts_sent"`
+	PartsFailed int       `json:"parts_failed"`
+	Error       string    `json:"error,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMS  int64     `json:"duration_ms"`
+}
+
+// finish fills in the outcome and timing of a delivery that began at start.
+func (r *Receipt) finish(start time.Time, err error) {
+	r.StartedAt = start.UTC()
+	r.DurationMS = time.Since(start).Milliseconds()
+	r.PartsSent = len(r.MessageIDs)
+	if r.Parts < r.PartsSent {
+		r.Parts = r.PartsSent
+	}
+	r.PartsFailed = r.Parts - r.PartsSent
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// delivered reports whether any part of the send reached Discord.
+func (r *Receipt) delivered() bool {
+	return r != nil && len(r.MessageIDs) > 0
+}
+
+// receiptWriter returns where receipts are printed. Passthrough owns stdout,
+// so receipts move to stderr to keep the echoed stream intact.
+func (c *CLI) receiptWriter() io.Writer {
+	if c.config.Passthrough {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// checkOutput rejects an unknown --output before anything is sent. Receipts
+// are printed last, and a config error after the messages went out would
+// make a retry post them twice.
+func (c *CLI) checkOutput() error {
+	switch c.config.Output {
+	case "", OutputText, OutputJSON:
+		return nil
+	}
+	return configErrorf("unknown output format %q (expected text or json)", c.config.Output)
+}
+
+// printReceipts prints the sent message IDs one per line, or the receipts as
+// JSON with --output json: a single object, or with routes an array with a
+// receipt per matching route, however many matched.
+func (c *CLI) printReceipts(receipts []*Receipt) error {
+	w := c.receiptWriter()
+
+	switch c.config.Output {
+	case OutputJSON:
+		var v interface{} = receipts
+		if len(c.config.Routes) == 0 && len(receipts) == 1 {
+			v = receipts[0]
+		} else if receipts == nil {
+			v = []*Receipt{}
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("error encoding receipt: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	case "", OutputText:
+		for _, r := range receipts {
+			for _, id := range r.MessageIDs {
+				fmt.Fprintln(w, id)
+			}
+		}
+	default:
+		return c.checkOutput()
+	}
+	return nil
+}
+
+// replyReference returns the message reference for --reply-to, if set.
+func (c *CLI) replyReference() *discordgo.MessageReference {
+	if c.replyTo == "" {
+		return nil
+	}
+	return &discordgo.MessageReference{
+		MessageID: c.replyTo,
+		ChannelID: c.config.ChannelID,
+		GuildID:   c.config.ServerID,
+	}
+}
+
+// checkCredentials verifies that a token and channel are configured.
+func (c *CLI) checkCredentials() error {
+	if c.config.Token == "" {
+		return configErrorf("discord token not configured")
+	}
+	if c.config.ChannelID == "" {
+		return configErrorf("discord channel ID not configured")
+	}
+	return nil
+}
+
+// messageArg returns the single message ID positional argument.
+func (c *CLI) messageArg(command string) (string, error) {
+	if len(c.args) != 1 {
+		return "", configErrorf("usage: disgo %s <message-id>", command)
+	}
+	return c.args[0], nil
+}
+
+// runEdit replaces the content of a previously sent message with stdin.
+func (c *CLI) runEdit() error {
+	messageID, err := c.messageArg("edit")
+	if err != nil {
+		return err
+	}
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+	if err := c.checkOutput(); err != nil {
+		return err
+	}
+	if len(c.stdinData) == 0 {
+		return errEmptyInput
+	}
+	if err := c.prepareContent(); err != nil {
+		return err
+	}
+	if err := c.applyLevel(); err != nil {
+		return err
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	content, allowed, err := c.withMentions(discord, string(c.stdinData))
+	if err != nil {
+		return err
+	}
+	parts := c.splitMessage(content)
+	if len(parts) > 1 {
+		return fmt.Errorf("content needs %d messages but edit replaces a single message", len(parts))
+	}
+
+	// Always set embeds so that the edit replaces or clears the old ones
+	partContent, embeds := c.formatPart(0, parts[0])
+	if embeds == nil {
+		embeds = []*discordgo.MessageEmbed{}
+	}
+	edit := discordgo.NewMessageEdit(c.config.ChannelID, messageID).SetContent(partContent).SetEmbeds(embeds)
+	edit.AllowedMentions = allowed
+	msg, err := discord.ChannelMessageEditComplex(edit)
+	if err != nil {
+		return fmt.Errorf("error editing message %s: %w", messageID, err)
+	}
+
+	return c.printReceipts([]*Receipt{{ChannelID: msg.ChannelID, MessageIDs: []string{msg.ID}}})
+}
+
+// runDelete removes a previously sent message.
+func (c *CLI) runDelete() error {
+	messageID, err := c.messageArg("delete")
+	if err != nil {
+		return err
+	}
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	if err := discord.ChannelMessageDelete(c.config.ChannelID, messageID); err != nil {
+		return fmt.Errorf("error deleting message %s: %w", messageID, err)
+	}
+	return nil
+}
+
+// prepareContent converts stdin according to the content options before it
+// is sent.
+func (c *CLI) prepareContent() error {
+	if len(c.stdinData) == 0 {
+		return nil
+	}
+	if c.config.InputFormat != "" && c.config.Format != "" {
+		// Records are structured data, not markup, and their embeds would
+		// replace the converted images
+		return configErrorf("--input-format and --format cannot be combined")
+	}
+	content, err := convertANSI(string(c.stdinData), c.config.ANSI)
+	if err != nil {
+		return err
+	}
+	if c.config.InputFormat != "" {
+		if content, err = c.convertInput(content); err != nil {
+			return err
+		}
+	}
+	switch {
+	case c.config.Format != "":
+		if content, err = c.formatRecords(content); err != nil {
+			return err
+		}
+	case c.config.ANSI == ANSIColor && strings.HasPrefix(content, fenceMarker+"ansi\n"):
+		// Colored output is already in an ansi code block
+	case c.config.Code != "":
+		content = wrapCode(content, c.codeLanguage(content))
+	}
+	c.stdinData = []byte(content)
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"disgo/fakediscord"
+)
+
+func TestSplitCommand(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected string
+		rest     int
+	}{
+		{args: nil, expected: "send", rest: 0},
+		{args: []string{"--debug"}, expected: "send", rest: 1},
+		{args: []string{"edit", "123"}, expected: "edit", rest: 1},
+		{args: []string{"delete", "123", "--channel", "x"}, expected: "delete", rest: 3},
+	}
+
+	for _, tc := range testCases {
+		name, rest := splitCommand(tc.args)
+		if name != tc.expected {
+			t.Errorf("Args %v: expected command %s, got %s", tc.args, tc.expected, name)
+		}
+		if len(rest) != tc.rest {
+			t.Errorf("Args %v: expected %d remaining args, got %d", tc.args, tc.rest, len(rest))
+		}
+	}
+}
+
+func TestParseFlagsAfterPositional(t *testing.T) {
+	cli := NewCLI()
+	err := cli.parseFlags([]string{"123", "--channel", "chan", "--reply-to", "456"})
+	if err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if len(cli.args) != 1 || cli.args[0] != "123" {
+		t.Errorf("Expected positional [123], got %v", cli.args)
+	}
+	if cli.channelID != "chan" {
+		t.Errorf("Expected channel 'chan', got %s", cli.channelID)
+	}
+
+	cli.mergeFlags()
+	ref := cli.replyReference()
+	if ref == nil || ref.MessageID != "456" || ref.ChannelID != "chan" {
+		t.Errorf("Unexpected reply reference %+v", ref)
+	}
+}
+
+func TestPrintReceiptsJSON(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+
+	cli := NewCLI()
+	cli.config.Output = OutputJSON
+	err = cli.printReceipts([]*Receipt{{ChannelID: "chan", MessageIDs: []string{"1", "2"}}})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Failed to print receipt: %v", err)
+	}
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	var receipt Receipt
+	if err := json.Unmarshal(buf.Bytes(), &receipt); err != nil {
+		t.Fatalf("Receipt is not valid JSON: %v (%q)", err, buf.String())
+	}
+	if receipt.ChannelID != "chan" || len(receipt.MessageIDs) != 2 {
+		t.Errorf("Unexpected receipt %+v", receipt)
+	}
+}
+
+// captureStdout runs fn and returns what it printed to stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+
+	runErr := fn()
+	w.Close()
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String(), runErr
+}
+
+func TestRunEditEmbeds(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	receipt, err := fakeCLI(srv, "original").sendToDiscord()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	id := receipt.MessageIDs[0]
+	edit := func(content string, setup func(cli *CLI)) {
+		t.Helper()
+		cli := fakeCLI(srv, content)
+		cli.args = []string{id}
+		setup(cli)
+		if _, err := captureStdout(t, cli.runEdit); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	edit(`[{"host": "web-1", "status": "up"}]`, func(cli *CLI) { cli.config.Format = FormatFields })
+	msg := srv.Messages("100")[0]
+	if msg.Content != "📋 1 record" || len(msg.Embeds) != 1 || len(msg.Embeds[0].Fields) != 2 {
+		t.Fatalf("Expected the caption with a record embed, got %q with %+v", msg.Content, msg.Embeds)
+	}
+
+	edit("disk full", func(cli *CLI) {
+		cli.level = LevelError
+		cli.config.LevelEmbeds = true
+	})
+	msg = srv.Messages("100")[0]
+	if msg.Content != "" || len(msg.Embeds) != 1 || msg.Embeds[0].Description != "❌ disk full" || msg.Embeds[0].Color == 0 {
+		t.Fatalf("Expected the text in a level embed, got %q with %+v", msg.Content, msg.Embeds)
+	}
+
+	edit("plain again", func(cli *CLI) {})
+	msg = srv.Messages("100")[0]
+	if msg.Content != "plain again" || len(msg.Embeds) != 0 {
+		t.Errorf("Expected the embeds to be cleared, got %q with %+v", msg.Content, msg.Embeds)
+	}
+}
+
+func TestEditDeleteAndReply(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	receipt, err := fakeCLI(srv, "deploy started").sendToDiscord()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	id := receipt.MessageIDs[0]
+
+	edit := fakeCLI(srv, "deploy succeeded")
+	edit.args = []string{id}
+	out, err := captureStdout(t, edit.runEdit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msgs := srv.Messages("100")
+	if len(msgs) != 1 || msgs[0].Content != "deploy succeeded" || msgs[0].EditedTimestamp == nil {
+		t.Fatalf("Expected the message to be edited in place, got %+v", msgs)
+	}
+	if out != id+"\n" {
+		t.Errorf("Expected the edited message ID, got %q", out)
+	}
+
+	tooLong := fakeCLI(srv, "alpha-one\nbravo-two")
+	tooLong.config.MaxMessageSize = 12
+	tooLong.args = []string{id}
+	if _, err := captureStdout(t, tooLong.runEdit); err == nil || !strings.Contains(err.Error(), "needs 2 messages") {
+		t.Errorf("Expected an error for content that needs two messages, got %v", err)
+	}
+	if msgs := srv.Messages("100"); msgs[0].Content != "deploy succeeded" {
+		t.Errorf("Expected the message to be left alone, got %q", msgs[0].Content)
+	}
+
+	reply := fakeCLI(srv, "rollback done")
+	reply.replyTo = id
+	if _, err := reply.sendToDiscord(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msgs = srv.Messages("100")
+	if len(msgs) != 2 || msgs[1].MessageReference == nil || msgs[1].MessageReference.MessageID != id {
+		t.Errorf("Expected a reply to %s, got %+v", id, msgs[len(msgs)-1])
+	}
+
+	del := fakeCLI(srv, "")
+	del.args = []string{id}
+	if err := del.runDelete(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msgs := srv.Messages("100"); len(msgs) != 1 || msgs[0].ID == id {
+		t.Errorf("Expected the message to be deleted, got %+v", msgs)
+	}
+	if err := del.runDelete(); err == nil || exitCode(err) == ExitConfig {
+		t.Errorf("Expected an API error deleting an unknown message, got %v", err)
+	}
+
+	del.args = nil
+	if err := del.runDelete(); exitCode(err) != ExitConfig {
+		t.Errorf("Expected a usage error without a message ID, got %v", err)
+	}
+}
+
+func TestUnknownOutputSendsNothing(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	send := fakeCLI(srv, "hello")
+	send.config.Output = "xml"
+	edit := fakeCLI(srv, "hello")
+	edit.config.Output = "xml"
+	edit.args = []string{"42"}
+
+	for name, run := range map[string]func() error{"send": send.runSend, "edit": edit.runEdit} {
+		if err := run(); exitCode(err) != ExitConfig {
+			t.Errorf("%s: expected a config error, got %v", name, err)
+		}
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("Expected nothing to reach Discord, got %d requests", n)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// PlannedPart is one message a send would post, or edit under --key.
+// Length is in bytes, the unit max_message_size limits; Chars counts the
+// characters Discord shows.
+type PlannedPart struct {
+	Length  int                       `json:"length"`
+	Chars   int                       `json:"chars"`
+	Content string                    `json:"content"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+	Edits   string                    `json:"edits,omitempty"`
+}
+
+// SendPlan describes what a send would do without doing it. It is printed by
+// --dry-run.
+type SendPlan struct {
+	Route      string        `json:"route,omitempty"`
+	Target     string        `json:"target"`
+	ChannelID  string        `json:"channel_id,omitempty"`
+	ThreadID   string        `json:"thread_id,omitempty"`
+	ThreadName string        `json:"thread_name,omitempty"`
+	Starter    string        `json:"starter,omitempty"`
+	WebhookID  string        `json:"webhook_id,omitempty"`
+	Username   string        `json:"username,omitempty"`
+	ReplyTo    string        `json:"reply_to,omitempty"`
+	Key        string        `json:"key,omitempty"`
+	Mentions   []string      `json:"mentions,omitempty"`
+	Reactions  []string      `json:"reactions,omitempty"`
+	Parts      []PlannedPart `json:"parts"`
+	Deletes    []string      `json:"deletes,omitempty"`
+}
+
+// previewMentions renders the mention prefix without contacting Discord.
+// Names that a send would look up in the server are shown as @name.
+func previewMentions(mentions []Mention) (string, []string) {
+	var prefix, specs []string
+	for _, m := range mentions {
+		switch m.Kind {
+		case MentionHere, MentionEveryone:
+			prefix = append(prefix, "@"+m.Kind)
+		case MentionUser:
+			if isSnowflake(m.Value) {
+				prefix = append(prefix, "<@"+m.Value+">")
+			} else {
+				prefix = append(prefix, "@"+m.Value)
+			}
+		case MentionRole:
+			if isSnowflake(m.Value) {
+				prefix = append(prefix, "<@&"+m.Value+">")
+			} else {
+				prefix = append(prefix, "@"+strings.TrimPrefix(m.Value, "@"))
+			}
+		}
+		specs = append(specs, m.String())
+	}
+	return strings.Join(prefix, " "), specs
+}
+
+// planParts splits and formats the content the way a send would.
+func (c *CLI) planParts(plan *SendPlan) error {
+	mentions, err := c.collectMentions()
+	if err != nil {
+		return err
+	}
+	prefix, specs := previewMentions(mentions)
+	plan.Mentions = specs
+
+	content := string(c.stdinData)
+	if prefix != "" {
+		content = prefix + "\n" + content
+	}
+	for i, part := range c.splitMessage(content) {
+		partContent, embeds := c.formatPart(i, part)
+		plan.Parts = append(plan.Parts, PlannedPart{
+			Length:  len(partContent),
+			Chars:   utf8.RuneCountInString(partContent),
+			Content: partContent,
+			Embeds:  embeds,
+		})
+	}
+	return nil
+}
+
+// plan resolves a send to the configured channel: the thread it would
+// create, the messages it would edit under --key and the parts it would post.
+func (c *CLI) plan() (*SendPlan, error) {
+	if c.config.ChannelID == "" {
+		return nil, configErrorf("discord channel ID not configured")
+	}
+
+	plan := &SendPlan{
+		Target:    "channel " + c.config.ChannelID,
+		ChannelID: c.config.ChannelID,
+		ReplyTo:   c.replyTo,
+		Key:       c.key,
+		Reactions: parseEmojiList(c.react),
+	}
+	if err := c.planParts(plan); err != nil {
+		return nil, err
+	}
+
+	if c.key != "" {
+		keys := make(map[string]KeyedMessage)
+		if err := loadState(c.keyStatePath(), &keys); err != nil {
+			return nil, err
+		}
+		if entry, ok := keys[c.key]; ok && entry.ChannelID == c.config.ChannelID {
+			if entry.ThreadID != "" {
+				plan.Target = "thread " + entry.ThreadID
+				plan.ThreadID = entry.ThreadID
+			}
+			for i := range plan.Parts {
+				if i < len(entry.MessageIDs) {
+					plan.Parts[i].Edits = entry.MessageIDs[i]
+				}
+			}
+			if len(entry.MessageIDs) > len(plan.Parts) {
+				plan.Deletes = entry.MessageIDs[len(plan.Parts):]
+			}
+			return plan, nil
+		}
+	}
+
+	if c.config.ThreadName != "" {
+		plan.Target = "new thread in channel " + c.config.ChannelID
+		plan.ThreadName = c.config.ThreadName
+		plan.Starter = fmt.Sprintf("📌 New thread: %s", c.config.ThreadName)
+	}
+	return plan, nil
+}
+
+// planWebhook resolves a send through a webhook. The token is left out so
+// plans are safe to print in CI logs.
+func (c *CLI) planWebhook(webhookURL, threadID string) (*SendPlan, error) {
+	id, _, err := parseWebhookURL(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	plan := &SendPlan{
+		Target:    "webhook " + id,
+		WebhookID: id,
+		ThreadID:  threadID,
+		Username:  c.config.Username,
+		Reactions: parseEmojiList(c.react),
+	}
+	if threadID != "" {
+		plan.Target += " (thread " + threadID + ")"
+	}
+	if err := c.planParts(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planSend resolves the send for every matching route, or for the
+// configured channel when there are no routes.
+func (c *CLI) planSend() ([]*SendPlan, error) {
+	if len(c.stdinData) == 0 {
+		return nil, nil // Nothing to send
+	}
+	if len(c.config.Routes) == 0 {
+		plan, err := c.plan()
+		if err != nil {
+			return nil, err
+		}
+		return []*SendPlan{plan}, nil
+	}
+
+	routes, err := c.selectRoutes(string(c.stdinData))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkRouteKeys(routes); err != nil {
+		return nil, err
+	}
+	var plans []*SendPlan
+	for _, r := range routes {
+		rc := c.withConfig(r.apply(c.config))
+		if c.key != "" {
+			rc.key = c.key + "@" + r.displayName()
+		}
+
+		var plan *SendPlan
+		if r.Webhook != "" {
+			plan, err = rc.planWebhook(r.Webhook, r.ThreadID)
+		} else {
+			plan, err = rc.plan()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", r.displayName(), err)
+		}
+		plan.Route = r.displayName()
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// describeEmbed summarises an embed on one line.
+func describeEmbed(e *discordgo.MessageEmbed) string {
+	var parts []string
+	if e.Title != "" {
+		parts = append(parts, fmt.Sprintf("title %q", e.Title))
+	}
+	if e.Description != "" {
+		parts = append(parts, fmt.Sprintf("%d chars", utf8.RuneCountInString(e.Description)))
+	}
+	if len(e.Fields) > 0 {
+		parts = append(parts, fmt.Sprintf("%d field%s", len(e.Fields), plural(len(e.Fields))))
+	}
+	if e.Color != 0 {
+		parts = append(parts, fmt.Sprintf("color #%06x", e.Color))
+	}
+	if e.Image != nil {
+		parts = append(parts, "image "+e.Image.URL)
+	}
+	if len(parts) == 0 {
+		return "empty"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writePlanText prints a plan for people: a header per setting, then each
+// part verbatim under a line giving its position and length.
+func writePlanText(w io.Writer, plan *SendPlan) {
+	target := plan.Target
+	if plan.Route != "" {
+		target += " (route " + plan.Route + ")"
+	}
+	fmt.Fprintf(w, "Target: %s\n", target)
+	if plan.ThreadName != "" {
+		fmt.Fprintf(w, "Thread: %s (starter %q)\n", plan.ThreadName, plan.Starter)
+	}
+	if plan.Username != "" {
+		fmt.Fprintf(w, "Username: %s\n", plan.Username)
+	}
+	if plan.ReplyTo != "" {
+		fmt.Fprintf(w, "Reply to: %s\n", plan.ReplyTo)
+	}
+	if len(plan.Mentions) > 0 {
+		fmt.Fprintf(w, "Mentions: %s\n", strings.Join(plan.Mentions, ", "))
+	}
+	if len(plan.Reactions) > 0 {
+		fmt.Fprintf(w, "Reactions: %s\n", strings.Join(plan.Reactions, " "))
+	}
+	if plan.Key != "" {
+		fmt.Fprintf(w, "Key: %s\n", plan.Key)
+	}
+	for i, part := range plan.Parts {
+		action := "Part"
+		if part.Edits != "" {
+			action = "Edit " + part.Edits + " with part"
+		}
+		fmt.Fprintf(w, "--- %s %d/%d (%d bytes, %d chars) ---\n", action, i+1, len(plan.Parts), part.Length, part.Chars)
+		if part.Content != "" {
+			fmt.Fprintln(w, strings.TrimSuffix(part.Content, "\n"))
+		}
+		for j, e := range part.Embeds {
+			fmt.Fprintf(w, "[embed %d: %s]\n", j+1, describeEmbed(e))
+		}
+	}
+	for _, id := range plan.Deletes {
+		fmt.Fprintf(w, "--- Delete %s ---\n", id)
+	}
+}
+
+// printPlans prints the send plans as text, or as JSON with --output json.
+// With list, JSON is always an array, so its shape doesn't depend on how many
+// routes or items matched.
+func (c *CLI) printPlans(plans []*SendPlan, list bool) error {
+	w := c.receiptWriter()
+
+	switch c.config.Output {
+	case OutputJSON:
+		var v interface{} = plans
+		if !list && len(plans) == 1 {
+			v = plans[0]
+		} else if plans == nil {
+			v = []*SendPlan{}
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding plan: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	case "", OutputText:
+		if len(plans) == 0 {
+			fmt.Fprintln(w, "Nothing to send")
+		}
+		for i, plan := range plans {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			writePlanText(w, plan)
+		}
+	default:
+		return configErrorf("unknown output format %q (expected text or json)", c.config.Output)
+	}
+	return nil
+}
+
+// runDryRun prints what runSend would send, without contacting Discord or
+// touching dedupe or key state.
+func (c *CLI) runDryRun() error {
+	plans, err := c.planSend()
+	if err != nil {
+		return err
+	}
+	return c.printPlans(plans, len(c.config.Routes) > 0)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPreviewMentions(t *testing.T) {
+	prefix, specs := previewMentions([]Mention{
+		{Kind: MentionUser, Value: "123"},
+		{Kind: MentionRole, Value: "oncall"},
+		{Kind: MentionHere},
+	})
+	if prefix != "<@123> @oncall @here" {
+		t.Errorf("Expected resolved IDs and placeholder names, got %q", prefix)
+	}
+	if strings.Join(specs, ",") != "user:123,role:oncall,here" {
+		t.Errorf("Expected mention specs, got %v", specs)
+	}
+}
+
+func TestPlanThreadAndParts(t *testing.T) {
+	cli := NewCLI()
+	cli.config.ChannelID = "100"
+	cli.config.ThreadName = "deploy"
+	cli.config.MessageMode = ModeSerialize
+	cli.config.MaxMessageSize = 12
+	cli.config.Mentions = []string{"role:456"}
+	cli.replyTo = "99"
+	cli.react = "✅, 🚀"
+	cli.stdinData = []byte("first line\nsecond line")
+
+	plans, err := cli.planSend()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("Expected one plan, got %d", len(plans))
+	}
+	plan := plans[0]
+	if plan.Target != "new thread in channel 100" || plan.Starter != "📌 New thread: deploy" {
+		t.Errorf("Expected a new thread target, got %+v", plan)
+	}
+	if len(plan.Parts) != 3 || plan.Parts[0].Content != "<@&456>\n" || plan.Parts[2].Length != 11 {
+		t.Errorf("Expected the mention then two parts, got %+v", plan.Parts)
+	}
+	if plan.ReplyTo != "99" || strings.Join(plan.Reactions, " ") != "✅ 🚀" {
+		t.Errorf("Expected reply and reactions, got %+v", plan)
+	}
+
+	var buf bytes.Buffer
+	writePlanText(&buf, plan)
+	expected := "" +
+		"Target: new thread in channel 100\n" +
+		"Thread: deploy (starter \"📌 New thread: deploy\")\n" +
+		"Reply to: 99\n" +
+		"Mentions: role:456\n" +
+		"Reactions: ✅ 🚀\n" +
+		"--- Part 1/3 (8 bytes, 8 chars) ---\n<@&456>\n" +
+		"--- Part 2/3 (11 bytes, 11 chars) ---\nfirst line\n" +
+		"--- Part 3/3 (11 bytes, 11 chars) ---\nsecond line\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestPlanPartLengthInBytes(t *testing.T) {
+	cli := NewCLI()
+	cli.config.ChannelID = "100"
+	cli.stdinData = []byte("héllo 🚀")
+
+	plans, err := cli.planSend()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if part := plans[0].Parts[0]; part.Length != 11 || part.Chars != 7 {
+		t.Errorf("Expected 11 bytes and 7 characters, got %d and %d", part.Length, part.Chars)
+	}
+}
+
+func TestPlanKeyedEdits(t *testing.T) {
+	cli := NewCLI()
+	cli.configPath = t.TempDir()
+	cli.config.ChannelID = "100"
+	cli.config.ThreadName = "ignored for edits"
+	cli.key = "status"
+	cli.stdinData = []byte("new status")
+
+	keys := map[string]KeyedMessage{
+		"status": {ChannelID: "100", ThreadID: "200", MessageIDs: []string{"1", "2"}, UpdatedAt: time.Now()},
+	}
+	if err := saveState(cli.keyStatePath(), keys); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	plan, err := cli.plan()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plan.Target != "thread 200" || plan.ThreadName != "" {
+		t.Errorf("Expected edits in the existing thread, got %+v", plan)
+	}
+	if plan.Parts[0].Edits != "1" || len(plan.Deletes) != 1 || plan.Deletes[0] != "2" {
+		t.Errorf("Expected one edit and one delete, got %+v", plan)
+	}
+}
+
+func TestPlanRoutesJSON(t *testing.T) {
+	cli := NewCLI()
+	cli.config.ChannelID = "100"
+	cli.config.Output = OutputJSON
+	cli.config.LevelEmbeds = true
+	cli.config.Routes = []Route{
+		{Name: "ops", ChannelID: "300"},
+		{Name: "hook", Webhook: "https://discord.com/api/webhooks/55/secret", ThreadID: "777"},
+	}
+	cli.level = "error"
+	cli.stdinData = []byte("db down")
+	if err := cli.applyLevel(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	plans, err := cli.planSend()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plans) != 2 {
+		t.Fatalf("Expected a plan per route, got %d", len(plans))
+	}
+	if plans[0].Route != "ops" || plans[0].ChannelID != "300" {
+		t.Errorf("Expected the ops channel, got %+v", plans[0])
+	}
+	if plans[1].Target != "webhook 55 (thread 777)" {
+		t.Errorf("Expected the webhook target, got %q", plans[1].Target)
+	}
+
+	data, err := json.Marshal(plans[1])
+	if err != nil {
+		t.Fatalf("Failed to encode plan: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Expected the webhook token to be left out, got %s", data)
+	}
+	var decoded SendPlan
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode plan: %v", err)
+	}
+	embeds := decoded.Parts[0].Embeds
+	if len(embeds) != 1 || embeds[0].Color != 0xe74c3c || !strings.Contains(embeds[0].Description, "db down") {
+		t.Errorf("Expected the level embed in the plan, got %+v", embeds)
+	}
+}
+
+func TestDescribeEmbed(t *testing.T) {
+	got := describeEmbed(&discordgo.MessageEmbed{
+		Title:       "Build",
+		Description: "failed",
+		Fields:      []*discordgo.MessageEmbedField{{Name: "a", Value: "b"}},
+		Color:       0x2ecc71,
+	})
+	if got != `title "Build", 6 chars, 1 field, color #2ecc71` {
+		t.Errorf("Unexpected description %q", got)
+	}
+}
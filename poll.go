@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord's limits on polls.
+const (
+	MaxPollQuestion = 300
+	MaxPollAnswer   = 55
+	MaxPollAnswers  = 10
+	MaxPollDuration = 32 * 24 * time.Hour
+
+	DefaultPollDuration = 24 * time.Hour
+)
+
+// discordgo has no poll types yet, so polls are sent and read with raw
+// requests using these.
+
+// PollMedia is the text of a poll question or answer.
+type PollMedia struct {
+	Text string `json:"text"`
+}
+
+// PollAnswer is one of the answers of a poll. Discord numbers them from 1.
+type PollAnswer struct {
+	AnswerID  int       `json:"answer_id,omitempty"`
+	PollMedia PollMedia `json:"poll_media"`
+}
+
+// PollAnswerCount is the number of votes for an answer. Answers without
+// votes are left out.
+type PollAnswerCount struct {
+	ID      int  `json:"id"`
+	Count   int  `json:"count"`
+	MeVoted bool `json:"me_voted"`
+}
+
+// PollResults are the votes of a poll so far, final once the poll closes.
+type PollResults struct {
+	IsFinalized  bool              `json:"is_finalized"`
+	AnswerCounts []PollAnswerCount `json:"answer_counts"`
+}
+
+// Poll is a poll attached to a message.
+type Poll struct {
+	Question         PollMedia    `json:"question"`
+	Answers          []PollAnswer `json:"answers"`
+	Expiry           *time.Time   `json:"expiry"`
+	AllowMultiselect bool         `json:"allow_multiselect"`
+	Results          *PollResults `json:"results"`
+}
+
+// pollRequest is the poll of a create message request. Duration is in
+// hours.
+type pollRequest struct {
+	Question         PollMedia    `json:"question"`
+	Answers          []PollAnswer `json:"answers"`
+	Duration         int          `json:"duration"`
+	AllowMultiselect bool         `json:"allow_multiselect"`
+	LayoutType       int          `json:"layout_type"`
+}
+
+// pollMessage is a create message request carrying a poll.
+type pollMessage struct {
+	Content         string                            `json:"content,omitempty"`
+	AllowedMentions *discordgo.MessageAllowedMentions `json:"allowed_mentions,omitempty"`
+	Poll            *pollRequest                      `json:"poll"`
+}
+
+// newPollRequest validates a poll against Discord's limits.
+func newPollRequest(question string, options []string, duration time.Duration, multi bool) (*pollRequest, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return nil, configErrorf("usage: disgo poll <question> --option A --option B [--duration 4h] [--multi]")
+	}
+	if n := len([]rune(question)); n > MaxPollQuestion {
+		return nil, configErrorf("poll question is %d characters (maximum %d)", n, MaxPollQuestion)
+	}
+	if len(options) == 0 || len(options) > MaxPollAnswers {
+		return nil, configErrorf("a poll needs 1 to %d --option answers, got %d", MaxPollAnswers, len(options))
+	}
+	if duration%time.Hour != 0 || duration < time.Hour || duration > MaxPollDuration {
+		return nil, configErrorf("invalid poll duration %s (expected whole hours from 1h to 32d)", duration)
+	}
+
+	poll := &pollRequest{
+		Question:         PollMedia{Text: question},
+		Duration:         int(duration / time.Hour),
+		AllowMultiselect: multi,
+		LayoutType:       1,
+	}
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, configErrorf("poll answers cannot be empty")
+		}
+		if n := len([]rune(option)); n > MaxPollAnswer {
+			return nil, configErrorf("poll answer %q is %d characters (maximum %d)", option, n, MaxPollAnswer)
+		}
+		poll.Answers = append(poll.Answers, PollAnswer{PollMedia: PollMedia{Text: option}})
+	}
+	return poll, nil
+}
+
+// pollDuration resolves --duration, defaulting to a day.
+func (c *CLI) pollDuration() (time.Duration, error) {
+	if c.pollFor == "" {
+		return DefaultPollDuration, nil
+	}
+	d, err := parseDays(c.pollFor)
+	if err != nil {
+		return 0, configErrorf("invalid --duration %q (expected whole hours like 4h or days like 2d)", c.pollFor)
+	}
+	return d, nil
+}
+
+// createPoll posts a poll to a channel or thread.
+func createPoll(discord DiscordClient, channelID string, data *pollMessage) (*discordgo.Message, error) {
+	endpoint := discordgo.EndpointChannelMessages(channelID)
+	body, err := discord.RequestWithBucketID(http.MethodPost, endpoint, data, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error creating poll: %w", err)
+	}
+	var msg discordgo.Message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("error decoding poll message: %w", err)
+	}
+	return &msg, nil
+}
+
+// fetchPoll returns the poll of a message.
+func fetchPoll(discord DiscordClient, channelID, messageID string) (*Poll, error) {
+	endpoint := discordgo.EndpointChannelMessage(channelID, messageID)
+	body, err := discord.RequestWithBucketID(http.MethodGet, endpoint, nil, discordgo.EndpointChannelMessage(channelID, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching message %s: %w", messageID, err)
+	}
+	// Decoded separately, as discordgo.Message has its own UnmarshalJSON
+	var msg struct {
+		Poll *Poll `json:"poll"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("error decoding message %s: %w", messageID, err)
+	}
+	if msg.Poll == nil {
+		return nil, configErrorf("message %s is not a poll", messageID)
+	}
+	return msg.Poll, nil
+}
+
+// PollSummary is the vote count of a poll, as printed by poll results.
+type PollSummary struct {
+	MessageID        string      `json:"message_id"`
+	ChannelID        string      `json:"channel_id"`
+	Question         string      `json:"question"`
+	AllowMultiselect bool        `json:"allow_multiselect"`
+	Expiry           *time.Time  `json:"expiry,omitempty"`
+	Finalized        bool        `json:"finalized"`
+	TotalVotes       int         `json:"total_votes"`
+	Answers          []PollTally `json:"answers"`
+}
+
+// PollTally is the votes for one answer.
+type PollTally struct {
+	ID      int    `json:"id"`
+	Text    string `json:"text"`
+	Votes   int    `json:"votes"`
+	Percent int    `json:"percent"`
+}
+
+// summarizePoll counts the votes for every answer of a poll, including
+// those Discord leaves out for having none.
+func summarizePoll(channelID, messageID string, poll *Poll) *PollSummary {
+	s := &PollSummary{
+		MessageID:        messageID,
+		ChannelID:        channelID,
+		Question:         poll.Question.Text,
+		AllowMultiselect: poll.AllowMultiselect,
+		Expiry:           poll.Expiry,
+		Answers:          []PollTally{},
+	}
+	counts := make(map[int]int)
+	if poll.Results != nil {
+		s.Finalized = poll.Results.IsFinalized
+		for _, ac := range poll.Results.AnswerCounts {
+			counts[ac.ID] = ac.Count
+			s.TotalVotes += ac.Count
+		}
+	}
+	for _, a := range poll.Answers {
+		tally := PollTally{ID: a.AnswerID, Text: a.PollMedia.Text, Votes: counts[a.AnswerID]}
+		if s.TotalVotes > 0 {
+			tally.Percent = int(math.Round(float64(tally.Votes) * 100 / float64(s.TotalVotes)))
+		}
+		s.Answers = append(s.Answers, tally)
+	}
+	return s
+}
+
+// writePollSummary prints the results as a bar chart, or as JSON with
+// --output json.
+func writePollSummary(w io.Writer, format string, s *PollSummary) error {
+	switch format {
+	case OutputJSON:
+		data, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("error encoding poll results: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	case "", OutputText:
+		var status []string
+		switch {
+		case s.Finalized:
+			status = append(status, "final results")
+		case s.Expiry != nil:
+			status = append(status, "closes "+s.Expiry.Local().Format("2006-01-02 15:04 MST"))
+		}
+		if s.AllowMultiselect {
+			status = append(status, "multiple answers")
+		}
+		if len(status) > 0 {
+			fmt.Fprintf(w, "%s (%s)\n", s.Question, strings.Join(status, ", "))
+		} else {
+			fmt.Fprintln(w, s.Question)
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, a := range s.Answers {
+			bar := strings.Repeat("█", a.Percent/5)
+			fmt.Fprintf(tw, "  %s\t%d\t%d%%\t%s\n", a.Text, a.Votes, a.Percent, bar)
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "%d vote%s\n", s.TotalVotes, plural(s.TotalVotes))
+	default:
+		return configErrorf("unknown output format %q (expected text or json)", format)
+	}
+	return nil
+}
+
+// PollPlan is the poll a dry run would create, as printed with --output
+// json.
+type PollPlan struct {
+	ChannelID string       `json:"channel_id"`
+	Poll      *pollRequest `json:"poll"`
+}
+
+// printPollPlan prints what runPoll would create, through the same text and
+// JSON switch as the plans of send.
+func (c *CLI) printPollPlan(channelID string, duration time.Duration, poll *pollRequest) error {
+	w := c.receiptWriter()
+
+	switch c.config.Output {
+	case OutputJSON:
+		data, err := json.MarshalIndent(PollPlan{ChannelID: channelID, Poll: poll}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding plan: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	case "", OutputText:
+		fmt.Fprintf(w, "Would create poll in %s, open for %s:\n", channelID, duration)
+		return writePollSummary(w, OutputText, summarizePoll(channelID, "", &Poll{
+			Question:         poll.Question,
+			Answers:          poll.Answers,
+			AllowMultiselect: poll.AllowMultiselect,
+		}))
+	default:
+		return configErrorf("unknown output format %q (expected text or json)", c.config.Output)
+	}
+	return nil
+}
+
+// runPoll creates a poll, or with `poll results ID` prints its votes. As
+// with read, --thread names the thread ID to post in or read from.
+func (c *CLI) runPoll() error {
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+	channelID := c.config.ChannelID
+	if c.threadName != "" {
+		channelID = c.threadName
+	}
+
+	if len(c.args) > 0 && c.args[0] == "results" {
+		if len(c.args) != 2 {
+			return configErrorf("usage: disgo poll results <message-id>")
+		}
+		return c.pollResults(channelID, c.args[1])
+	}
+
+	if err := c.checkOutput(); err != nil {
+		return err
+	}
+	if len(c.args) > 1 {
+		return configErrorf("quote the poll question, got %d arguments", len(c.args))
+	}
+	question := ""
+	if len(c.args) == 1 {
+		question = c.args[0]
+	}
+	duration, err := c.pollDuration()
+	if err != nil {
+		return err
+	}
+	poll, err := newPollRequest(question, c.pollOptions, duration, c.multi)
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		return c.printPollPlan(channelID, duration, poll)
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	content, allowed, err := c.withMentions(discord, "")
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	msg, err := createPoll(discord, channelID, &pollMessage{
+		Content:         strings.TrimSuffix(content, "\n"),
+		AllowedMentions: allowed,
+		Poll:            poll,
+	})
+	if err != nil {
+		return err
+	}
+	receipt := &Receipt{ChannelID: channelID, MessageIDs: []string{msg.ID}, Parts: 1}
+	receipt.finish(start, nil)
+	return c.printReceipts([]*Receipt{receipt})
+}
+
+// pollResults prints the votes of the poll in a message.
+func (c *CLI) pollResults(channelID, messageID string) error {
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	poll, err := fetchPoll(discord, channelID, messageID)
+	if err != nil {
+		return err
+	}
+	return writePollSummary(os.Stdout, c.config.Output, summarizePoll(channelID, messageID, poll))
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"disgo/fakediscord"
+)
+
+// runPollOutput runs the poll command and returns what it printed.
+func runPollOutput(t *testing.T, cli *CLI) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = oldStdout
+	}()
+
+	runErr := cli.runPoll()
+	w.Close()
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String(), runErr
+}
+
+func TestNewPollRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		question string
+		options  []string
+		duration time.Duration
+		wantErr  bool
+	}{
+		{"valid", "Lunch?", []string{"Pizza", "Sushi"}, 4 * time.Hour, false},
+		{"longest", "Lunch?", []string{"Pizza"}, 32 * 24 * time.Hour, false},
+		{"no question", " ", []string{"Pizza"}, time.Hour, true},
+		{"no options", "Lunch?", nil, time.Hour, true},
+		{"too many options", "Lunch?", strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), time.Hour, true},
+		{"empty option", "Lunch?", []string{"Pizza", ""}, time.Hour, true},
+		{"long option", "Lunch?", []string{strings.Repeat("x", 56)}, time.Hour, true},
+		{"long question", strings.Repeat("x", 301), []string{"Pizza"}, time.Hour, true},
+		{"partial hour", "Lunch?", []string{"Pizza"}, 90 * time.Minute, true},
+		{"too long", "Lunch?", []string{"Pizza"}, 33 * 24 * time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll, err := newPollRequest(tt.question, tt.options, tt.duration, false)
+			if tt.wantErr {
+				if exitCode(err) != ExitConfig {
+					t.Errorf("Expected a config error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if poll.Duration != int(tt.duration/time.Hour) || len(poll.Answers) != len(tt.options) {
+				t.Errorf("Expected %d answers for %d hours, got %+v", len(tt.options), tt.duration/time.Hour, poll)
+			}
+		})
+	}
+}
+
+func TestPoll(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "")
+	cli.args = []string{"Lunch?"}
+	cli.pollOptions = stringList{"Pizza", "Sushi", "Salad"}
+	cli.pollFor = "4h"
+	cli.multi = true
+
+	out, err := runPollOutput(t, cli)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msgs := srv.Messages("100")
+	if len(msgs) != 1 || strings.TrimSpace(out) != msgs[0].ID {
+		t.Fatalf("Expected the poll message ID to be printed, got %q for %+v", out, msgs)
+	}
+	poll := srv.Poll(msgs[0].ID)
+	if poll == nil || poll.Question.Text != "Lunch?" || len(poll.Answers) != 3 || !poll.AllowMultiselect {
+		t.Fatalf("Expected a multi-select poll with three answers, got %+v", poll)
+	}
+	if left := time.Until(poll.Expiry); left < 3*time.Hour || left > 4*time.Hour {
+		t.Errorf("Expected the poll to close in 4h, got %s", left)
+	}
+
+	srv.Vote(msgs[0].ID, 1, 3)
+	srv.Vote(msgs[0].ID, 2, 1)
+	srv.EndPoll(msgs[0].ID)
+
+	results := fakeCLI(srv, "")
+	results.args = []string{"results", msgs[0].ID}
+	results.config.Output = OutputJSON
+	out, err = runPollOutput(t, results)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var summary PollSummary
+	if err := json.Unmarshal([]byte(out), &summary); err != nil {
+		t.Fatalf("Results are not valid JSON: %v (%q)", err, out)
+	}
+	if !summary.Finalized || summary.TotalVotes != 4 || len(summary.Answers) != 3 {
+		t.Fatalf("Expected 4 final votes over three answers, got %+v", summary)
+	}
+	want := []PollTally{{1, "Pizza", 3, 75}, {2, "Sushi", 1, 25}, {3, "Salad", 0, 0}}
+	for i, a := range summary.Answers {
+		if a != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], a)
+		}
+	}
+
+	results.config.Output = OutputText
+	out, err = runPollOutput(t, results)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, line := range []string{"Lunch? (final results, multiple answers)", "Pizza  3  75%  ███████████████", "Salad  0  0%", "4 votes"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected %q in results, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestPollThread(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "")
+	cli.args = []string{"Ship it?"}
+	cli.pollOptions = stringList{"Go", "No go"}
+	cli.threadName = "200"
+	if _, err := runPollOutput(t, cli); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(srv.Messages("100")) != 0 || len(srv.Messages("200")) != 1 {
+		t.Errorf("Expected the poll in the thread only")
+	}
+	if poll := srv.Poll(srv.Messages("200")[0].ID); poll == nil || time.Until(poll.Expiry) < 23*time.Hour {
+		t.Errorf("Expected a poll open for a day by default, got %+v", poll)
+	}
+}
+
+func TestPollResultsNotAPoll(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	if _, err := fakeCLI(srv, "plain message").sendToDiscord(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cli := fakeCLI(srv, "")
+	cli.args = []string{"results", srv.Messages("100")[0].ID}
+	if _, err := runPollOutput(t, cli); err == nil || !strings.Contains(err.Error(), "is not a poll") {
+		t.Errorf("Expected a not a poll error, got %v", err)
+	}
+
+	cli.args = []string{"results", "42"}
+	if _, err := runPollOutput(t, cli); err == nil || exitCode(err) == ExitConfig {
+		t.Errorf("Expected an API error for an unknown message, got %v", err)
+	}
+}
+
+func TestPollDryRun(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "")
+	cli.args = []string{"Lunch?"}
+	cli.pollOptions = stringList{"Pizza", "Sushi"}
+	cli.pollFor = "4h"
+	cli.dryRun = true
+	cli.config.Output = OutputJSON
+
+	out, err := runPollOutput(t, cli)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var plan PollPlan
+	if err := json.Unmarshal([]byte(out), &plan); err != nil {
+		t.Fatalf("Plan is not valid JSON: %v (%q)", err, out)
+	}
+	if plan.ChannelID != "100" || plan.Poll.Question.Text != "Lunch?" || plan.Poll.Duration != 4 || len(plan.Poll.Answers) != 2 {
+		t.Errorf("Expected the planned poll, got %+v", plan)
+	}
+
+	cli.config.Output = OutputText
+	out, err = runPollOutput(t, cli)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "Would create poll in 100, open for 4h0m0s:") || !strings.Contains(out, "Sushi") {
+		t.Errorf("Expected a text plan, got:\n%s", out)
+	}
+	if len(srv.Requests()) != 0 {
+		t.Errorf("Expected no requests on a dry run, got %d", len(srv.Requests()))
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Which sent part --react applies to.
+const (
+	ReactFirst = "first"
+	ReactLast  = "last"
+)
+
+// parseEmojiList splits a comma-separated emoji list.
+func parseEmojiList(list string) []string {
+	var emojis []string
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emojis = append(emojis, e)
+		}
+	}
+	return emojis
+}
+
+// checkReactTo rejects an invalid --react-to before anything is sent, as
+// reactions are only added once the messages are out.
+func (c *CLI) checkReactTo() error {
+	switch c.reactTo {
+	case "", ReactFirst, ReactLast:
+		return nil
+	}
+	return configErrorf("invalid --react-to %q (expected first or last)", c.reactTo)
+}
+
+// isCustomEmojiName reports whether e names a custom emoji (e.g. shipit or
+// :shipit:) rather than being a unicode emoji.
+func isCustomEmojiName(e string) bool {
+	e = strings.Trim(e, ":")
+	if e == "" {
+		return false
+	}
+	for _, r := range e {
+		if !(r == '_' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
+// emojiResolver turns emoji given on the command line into the form the
+// reaction endpoints expect. Custom emoji are looked up by name in the guild
+// given by ServerID.
+type emojiResolver struct {
+	discord DiscordClient
+	guildID string
+	emojis  []*discordgo.Emoji
+}
+
+func (r *emojiResolver) resolve(e string) (string, error) {
+	// <:name:id> and <a:name:id> as copied from a message
+	if strings.HasPrefix(e, "<") && strings.HasSuffix(e, ">") {
+		parts := strings.Split(strings.Trim(e, "<>"), ":")
+		if len(parts) == 3 {
+			return parts[1] + ":" + parts[2], nil
+		}
+		return "", configErrorf("invalid emoji %q", e)
+	}
+	// name:id is already in API form
+	if name, id, ok := strings.Cut(e, ":"); ok && name != "" && isSnowflake(id) {
+		return e, nil
+	}
+	if !isCustomEmojiName(e) {
+		return e, nil
+	}
+
+	if r.guildID == "" {
+		return "", configErrorf("cannot resolve emoji %q: server ID not configured", e)
+	}
+	if r.emojis == nil {
+		emojis, err := r.discord.GuildEmojis(r.guildID)
+		if err != nil {
+			return "", fmt.Errorf("error fetching emoji: %w", err)
+		}
+		r.emojis = emojis
+	}
+	name := strings.Trim(e, ":")
+	for _, emoji := range r.emojis {
+		if strings.EqualFold(emoji.Name, name) {
+			return emoji.APIName(), nil
+		}
+	}
+	return "", fmt.Errorf("emoji %q not found in server %s", name, r.guildID)
+}
+
+// addReactions reacts to a message with each emoji in order.
+func (c *CLI) addReactions(discord DiscordClient, channelID, messageID string, emojis []string) error {
+	resolver := &emojiResolver{discord: discord, guildID: c.config.ServerID}
+	for _, e := range emojis {
+		name, err := resolver.resolve(e)
+		if err != nil {
+			return err
+		}
+		if err := discord.MessageReactionAdd(channelID, messageID, name); err != nil {
+			return fmt.Errorf("error adding reaction %s: %w", e, err)
+		}
+	}
+	return nil
+}
+
+// reactToReceipts applies --react to the first or last part of each receipt.
+func (c *CLI) reactToReceipts(receipts []*Receipt) error {
+	emojis := parseEmojiList(c.react)
+	if len(emojis) == 0 {
+		return nil
+	}
+	if err := c.checkReactTo(); err != nil {
+		return err
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	for _, r := range receipts {
+		if len(r.MessageIDs) == 0 {
+			continue
+		}
+		messageID := r.MessageIDs[0]
+		if c.reactTo == ReactLast {
+			messageID = r.MessageIDs[len(r.MessageIDs)-1]
+		}
+
+		channelID := r.ChannelID
+		if r.ThreadID != "" {
+			channelID = r.ThreadID
+		}
+		if err := c.addReactions(discord, channelID, messageID, emojis); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runReact adds or, with --remove, removes the bot's reactions on an
+// existing message.
+func (c *CLI) runReact() error {
+	if len(c.args) < 2 {
+		return configErrorf("usage: disgo react <message-id> <emoji>...")
+	}
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+
+	messageID := c.args[0]
+	var emojis []string
+	for _, arg := range c.args[1:] {
+		emojis = append(emojis, parseEmojiList(arg)...)
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	if !c.remove {
+		return c.addReactions(discord, c.config.ChannelID, messageID, emojis)
+	}
+
+	resolver := &emojiResolver{discord: discord, guildID: c.config.ServerID}
+	for _, e := range emojis {
+		name, err := resolver.resolve(e)
+		if err != nil {
+			return err
+		}
+		if err := discord.MessageReactionRemove(c.config.ChannelID, messageID, name, "@me"); err != nil {
+			return fmt.Errorf("error removing reaction %s: %w", e, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+
+	"disgo/fakediscord"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseEmojiList(t *testing.T) {
+	emojis := parseEmojiList("✅, 🚀,,shipit")
+	if len(emojis) != 3 || emojis[0] != "✅" || emojis[1] != "🚀" || emojis[2] != "shipit" {
+		t.Errorf("Unexpected emoji list %q", emojis)
+	}
+}
+
+func TestEmojiResolve(t *testing.T) {
+	resolver := &emojiResolver{}
+
+	testCases := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "✅", expected: "✅"},
+		{input: "<:shipit:123>", expected: "shipit:123"},
+		{input: "<a:party:456>", expected: "party:456"},
+		{input: "shipit:123", expected: "shipit:123"},
+		// Custom names need ServerID to resolve
+		{input: ":shipit:", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			name, err := resolver.resolve(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, name)
+			}
+		})
+	}
+}
+
+// reactions returns the bot's own reactions on a message by API name.
+func reactions(srv *fakediscord.Server, channelID, messageID string) map[string]bool {
+	mine := make(map[string]bool)
+	for _, m := range srv.Messages(channelID) {
+		if m.ID != messageID {
+			continue
+		}
+		for _, r := range m.Reactions {
+			if r.Me {
+				mine[r.Emoji.APIName()] = true
+			}
+		}
+	}
+	return mine
+}
+
+func TestRunReact(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	srv.SetEmojis("42", []*discordgo.Emoji{{ID: "777", Name: "shipit"}})
+
+	receipt, err := fakeCLI(srv, "deploy started").sendToDiscord()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	id := receipt.MessageIDs[0]
+
+	cli := fakeCLI(srv, "")
+	cli.config.ServerID = "42"
+	cli.args = []string{id, "✅,🚀", ":shipit:"}
+	if err := cli.runReact(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := reactions(srv, "100", id); len(got) != 3 || !got["✅"] || !got["🚀"] || !got["shipit:777"] {
+		t.Fatalf("Expected three reactions including the custom emoji, got %v", got)
+	}
+
+	cli.args = []string{id, "🚀"}
+	cli.remove = true
+	if err := cli.runReact(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := reactions(srv, "100", id); len(got) != 2 || got["🚀"] {
+		t.Errorf("Expected 🚀 to be removed, got %v", got)
+	}
+
+	cli.args = []string{id, ":missing:"}
+	cli.remove = false
+	if err := cli.runReact(); err == nil {
+		t.Error("Expected an error for an unknown custom emoji")
+	}
+}
+
+func TestReactToReceipts(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	for _, reactTo := range []string{ReactFirst, ReactLast} {
+		cli := fakeCLI(srv, "alpha-one\nbravo-two\ncharlie-3")
+		cli.config.MaxMessageSize = 12
+		cli.react = "👀"
+		cli.reactTo = reactTo
+		receipts, err := cli.send()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ids := receipts[0].MessageIDs
+		if len(ids) != 3 {
+			t.Fatalf("Expected three parts, got %v", ids)
+		}
+		want := ids[0]
+		if reactTo == ReactLast {
+			want = ids[2]
+		}
+		for _, id := range ids {
+			if got := reactions(srv, "100", id)["👀"]; got != (id == want) {
+				t.Errorf("%s: expected a reaction only on %s, got %v on %s", reactTo, want, got, id)
+			}
+		}
+	}
+}
+
+func TestInvalidReactToSendsNothing(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "deploy started")
+	cli.react = "✅"
+	cli.reactTo = "middle"
+	if err := cli.runSend(); exitCode(err) != ExitConfig {
+		t.Errorf("Expected a config error, got %v", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("Expected nothing to reach Discord, got %d requests", n)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// OutputNDJSON prints one JSON object per line.
+const OutputNDJSON = "ndjson"
+
+// DefaultReadLimit is the number of messages read prints when --limit is unset.
+const DefaultReadLimit = 100
+
+// maxPageSize is the most messages Discord returns per history request.
+const maxPageSize = 100
+
+// HistoryMessage is the printed form of a channel message.
+type HistoryMessage struct {
+	ID          string    `json:"id"`
+	ChannelID   string    `json:"channel_id"`
+	AuthorID    string    `json:"author_id"`
+	Author      string    `json:"author"`
+	Bot         bool      `json:"bot,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Content     string    `json:"content"`
+	Attachments []string  `json:"attachments,omitempty"`
+}
+
+func newHistoryMessage(m *discordgo.Message) HistoryMessage {
+	hm := HistoryMessage{
+		ID:        m.ID,
+		ChannelID: m.ChannelID,
+		Timestamp: m.Timestamp,
+		Content:   m.Content,
+	}
+	if m.Author != nil {
+		hm.AuthorID = m.Author.ID
+		hm.Author = m.Author.Username
+		hm.Bot = m.Author.Bot
+	}
+	for _, a := range m.Attachments {
+		hm.Attachments = append(hm.Attachments, a.URL)
+	}
+	return hm
+}
+
+// parseSince accepts a duration such as 90m, 1h or 2d, or an RFC 3339
+// timestamp, and returns the earliest time to include.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(since, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(since, "d"))
+		if err != nil {
+			return time.Time{}, configErrorf("invalid --since %q", since)
+		}
+		return now.Add(-time.Duration(days) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return time.Time{}, configErrorf("invalid --since %q (expected a duration like 1h or an RFC 3339 time)", since)
+	}
+	return now.Add(-d), nil
+}
+
+// historyFilter selects which messages read prints.
+type historyFilter struct {
+	since   time.Time
+	author  string
+	pattern *regexp.Regexp
+}
+
+func (f historyFilter) match(m *discordgo.Message) bool {
+	if f.author != "" {
+		if m.Author == nil {
+			return false
+		}
+		if m.Author.ID != f.author && !strings.EqualFold(m.Author.Username, f.author) {
+			return false
+		}
+	}
+	if f.pattern != nil && !f.pattern.MatchString(m.Content) {
+		return false
+	}
+	return true
+}
+
+// fetchHistory pages backwards through a channel until limit messages match,
+// the history runs out or messages become older than the filter's since.
+// Messages are returned oldest first.
+func fetchHistory(discord DiscordClient, channelID string, limit int, filter historyFilter) ([]*discordgo.Message, error) {
+	var matched []*discordgo.Message
+	beforeID := ""
+	for len(matched) < limit {
+		page, err := discord.ChannelMessages(channelID, maxPageSize, beforeID, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("error fetching messages: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		done := false
+		for _, m := range page {
+			if !filter.since.IsZero() && m.Timestamp.Before(filter.since) {
+				done = true
+				break
+			}
+			if filter.match(m) {
+				matched = append(matched, m)
+				if len(matched) == limit {
+					done = true
+					break
+				}
+			}
+		}
+		if done || len(page) < maxPageSize {
+			break
+		}
+		beforeID = page[len(page)-1].ID
+	}
+
+	// Discord returns newest first; print in reading order
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return matched, nil
+}
+
+// writeHistory prints messages as text, a JSON array or NDJSON.
+func writeHistory(w io.Writer, format string, messages []*discordgo.Message) error {
+	switch format {
+	case "", OutputText:
+		for _, m := range messages {
+			hm := newHistoryMessage(m)
+			fmt.Fprintf(w, "[%s] %s: %s\n", hm.Timestamp.Local().Format("2006-01-02 15:04:05"), hm.Author, hm.Content)
+			for _, a := range hm.Attachments {
+				fmt.Fprintf(w, "    attachment: %s\n", a)
+			}
+		}
+	case OutputJSON:
+		out := make([]HistoryMessage, 0, len(messages))
+		for _, m := range messages {
+			out = append(out, newHistoryMessage(m))
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding messages: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	case OutputNDJSON:
+		enc := json.NewEncoder(w)
+		for _, m := range messages {
+			if err := enc.Encode(newHistoryMessage(m)); err != nil {
+				return fmt.Errorf("error encoding message: %w", err)
+			}
+		}
+	default:
+		return configErrorf("unknown output format %q (expected text, json or ndjson)", format)
+	}
+	return nil
+}
+
+// runRead prints channel or thread history to stdout.
+func (c *CLI) runRead() error {
+	if err := c.checkCredentials(); err != nil {
+		return err
+	}
+
+	since, err := parseSince(c.since, time.Now())
+	if err != nil {
+		return err
+	}
+	filter := historyFilter{since: since, author: c.author}
+	if c.match != "" {
+		filter.pattern, err = regexp.Compile(c.match)
+		if err != nil {
+			return configErrorf("invalid --match pattern: %w", err)
+		}
+	}
+
+	limit := c.limit
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+
+	// For read, --thread names the thread ID to read instead of a thread
+	// to create
+	channelID := c.config.ChannelID
+	if c.threadName != "" {
+		channelID = c.threadName
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer discord.Close()
+
+	messages, err := fetchHistory(discord, channelID, limit, filter)
+	if err != nil {
+		return err
+	}
+	return writeHistory(os.Stdout, c.config.Output, messages)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"disgo/fakediscord"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		since    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{since: "", expected: time.Time{}},
+		{since: "1h", expected: now.Add(-time.Hour)},
+		{since: "2d", expected: now.Add(-48 * time.Hour)},
+		{since: "2026-10-17T09:00:00Z", expected: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)},
+		{since: "yesterday", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.since, func(t *testing.T) {
+			got, err := parseSince(tc.since, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tc.since)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !got.Equal(tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	msg := &discordgo.Message{
+		Content: "deploy failed: timeout",
+		Author:  &discordgo.User{ID: "42", Username: "ci-bot"},
+	}
+
+	testCases := []struct {
+		name     string
+		filter   historyFilter
+		expected bool
+	}{
+		{name: "No filter", filter: historyFilter{}, expected: true},
+		{name: "Author by ID", filter: historyFilter{author: "42"}, expected: true},
+		{name: "Author by name", filter: historyFilter{author: "CI-BOT"}, expected: true},
+		{name: "Other author", filter: historyFilter{author: "alice"}, expected: false},
+		{name: "Pattern match", filter: historyFilter{pattern: regexp.MustCompile("fail")}, expected: true},
+		{name: "Pattern miss", filter: historyFilter{pattern: regexp.MustCompile("^ok")}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.match(msg); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteHistoryNDJSON(t *testing.T) {
+	messages := []*discordgo.Message{
+		{ID: "1", Content: "first", Author: &discordgo.User{ID: "42", Username: "ci-bot"}},
+		{ID: "2", Content: "second", Author: &discordgo.User{ID: "42", Username: "ci-bot"}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeHistory(&buf, OutputNDJSON, messages); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var hm HistoryMessage
+	if err := json.Unmarshal([]byte(lines[1]), &hm); err != nil {
+		t.Fatalf("Line is not valid JSON: %v", err)
+	}
+	if hm.ID != "2" || hm.Author != "ci-bot" || hm.Content != "second" {
+		t.Errorf("Unexpected message %+v", hm)
+	}
+
+	if err := writeHistory(&buf, "xml", messages); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestFetchHistoryPaging(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "")
+	discord, err := cli.newClient()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer discord.Close()
+	for i := 0; i < 250; i++ {
+		if _, err := discord.ChannelMessageSendComplex("100", &discordgo.MessageSend{Content: fmt.Sprintf("msg %d", i)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	pages := func(fetch func()) []string {
+		t.Helper()
+		before := len(srv.Requests())
+		fetch()
+		var cursors []string
+		for _, r := range srv.Requests()[before:] {
+			cursors = append(cursors, r.Query.Get("before"))
+		}
+		return cursors
+	}
+
+	var msgs []*discordgo.Message
+	cursors := pages(func() { msgs, err = fetchHistory(discord, "100", 120, historyFilter{}) })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(msgs) != 120 || msgs[0].Content != "msg 130" || msgs[119].Content != "msg 249" {
+		t.Fatalf("Expected the newest 120 messages oldest first, got %d from %q", len(msgs), msgs[0].Content)
+	}
+	if len(cursors) != 2 || cursors[0] != "" || cursors[1] == "" {
+		t.Errorf("Expected a second page before the first, got cursors %q", cursors)
+	}
+
+	filter := historyFilter{pattern: regexp.MustCompile(`^msg 1\d$`)}
+	cursors = pages(func() { msgs, err = fetchHistory(discord, "100", 100, filter) })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(msgs) != 10 || msgs[0].Content != "msg 10" || msgs[9].Content != "msg 19" {
+		t.Errorf("Expected msg 10 to msg 19, got %d messages", len(msgs))
+	}
+	if len(cursors) != 3 {
+		t.Errorf("Expected the whole history to be paged through, got %d requests", len(cursors))
+	}
+
+	filter = historyFilter{since: time.Now().Add(time.Hour)}
+	cursors = pages(func() { msgs, err = fetchHistory(discord, "100", 100, filter) })
+	if err != nil || len(msgs) != 0 || len(cursors) != 1 {
+		t.Errorf("Expected paging to stop at the first older message, got %d messages in %d requests (%v)", len(msgs), len(cursors), err)
+	}
+}
+
+func TestRunRead(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	for _, content := range []string{"deploy started", "tests passed", "deploy finished"} {
+		if _, err := fakeCLI(srv, content).sendToDiscord(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	cli := fakeCLI(srv, "")
+	cli.match = "^deploy"
+	cli.limit = 5
+	cli.config.Output = OutputNDJSON
+	out, err := captureStdout(t, cli.runRead)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected two matching messages, got:\n%s", out)
+	}
+	var hm HistoryMessage
+	if err := json.Unmarshal([]byte(lines[1]), &hm); err != nil || hm.Content != "deploy finished" {
+		t.Errorf("Expected the newest match last, got %+v (%v)", hm, err)
+	}
+}
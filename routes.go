@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Route sends the message to an additional destination when its match rules
+// are satisfied. Fields left empty inherit the value from the base config.
+type Route struct {
+	Name           string            `yaml:"name"`
+	Match          RouteMatch        `yaml:"match"`
+	ChannelID      string            `yaml:"channel_id"`
+	ThreadID       string            `yaml:"thread_id"`
+	ThreadName     string            `yaml:"thread_name"`
+	Webhook        string            `yaml:"webhook"`
+	Username       string            `yaml:"username"`
+	Tags           []string          `yaml:"tags"`
+	TagMode        string            `yaml:"tag_mode"`
+	Properties     map[string]string `yaml:"properties"`
+	PropertyMode   string            `yaml:"property_mode"`
+	MaxMessageSize int               `yaml:"max_message_size"`
+	MessageMode    string            `yaml:"message_mode"`
+	Stop           bool              `yaml:"stop"`
+}
+
+// RouteMatch describes when a route applies. All non-empty rules must match;
+// a route without rules matches every message.
+type RouteMatch struct {
+	Tags       []string          `yaml:"tags"`       // any of these tags
+	Properties map[string]string `yaml:"properties"` // all of these key/value pairs
+	Content    string            `yaml:"content"`    // regular expression over the content
+}
+
+// RouteResult records the outcome of delivering to a single route.
+type RouteResult struct {
+	Route   string
+	Target  string
+	Receipt *Receipt
+	Err     error
+}
+
+// matches reports whether the route applies to the given config and content.
+func (r Route) matches(cfg Config, content string) (bool, error) {
+	if len(r.Match.Tags) > 0 {
+		found := false
+		for _, want := range r.Match.Tags {
+			for _, have := range cfg.Tags {
+				if strings.EqualFold(want, have) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	for k, v := range r.Match.Properties {
+		if cfg.Properties[k] != v {
+			return false, nil
+		}
+	}
+
+	if r.Match.Content != "" {
+		re, err := regexp.Compile(r.Match.Content)
+		if err != nil {
+			return false, fmt.Errorf("route %s: invalid content pattern: %w", r.displayName(), err)
+		}
+		if !re.MatchString(content) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// apply returns a copy of cfg with the route's overrides applied. Tags and
+// properties follow the same merge semantics as command line flags.
+func (r Route) apply(cfg Config) Config {
+	out := cfg
+	out.Tags = append([]string(nil), cfg.Tags...)
+	out.Properties = make(map[string]string, len(cfg.Properties))
+	for k, v := range cfg.Properties {
+		out.Properties[k] = v
+	}
+
+	if r.ChannelID != "" {
+		out.ChannelID = r.ChannelID
+	}
+	if r.ThreadID != "" {
+		// Threads are channels, so sending into an existing one only
+		// needs its ID as the target.
+		out.ChannelID = r.ThreadID
+		out.ThreadName = ""
+	}
+	if r.ThreadName != "" {
+		out.ThreadName = r.ThreadName
+	}
+	if r.Username != "" {
+		out.Username = r.Username
+	}
+	if r.MaxMessageSize > 0 {
+		out.MaxMessageSize = r.MaxMessageSize
+	}
+	if r.MessageMode != "" {
+		out.MessageMode = r.MessageMode
+	}
+	if r.TagMode != "" {
+		out.TagMode = r.TagMode
+	}
+	if r.PropertyMode != "" {
+		out.PropertyMode = r.PropertyMode
+	}
+	if len(r.Tags) > 0 {
+		out.Tags = mergeTags(out.Tags, r.Tags, out.TagMode)
+	}
+	if len(r.Properties) > 0 {
+		out.Properties = mergeProperties(out.Properties, r.Properties, out.PropertyMode)
+	}
+	out.Routes = nil
+	return out
+}
+
+func (r Route) displayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	switch {
+	case r.Webhook != "":
+		return "webhook"
+	case r.ThreadID != "":
+		return "thread " + r.ThreadID
+	case r.ChannelID != "":
+		return "channel " + r.ChannelID
+	}
+	return "default"
+}
+
+// target describes where a route delivers to, for reporting.
+func (r Route) target(cfg Config) string {
+	if r.Webhook != "" {
+		if r.ThreadID != "" {
+			return "webhook (thread " + r.ThreadID + ")"
+		}
+		return "webhook"
+	}
+	return "channel " + cfg.ChannelID
+}
+
+// selectRoutes returns the routes that match the current message, in config
+// order, stopping after the first matching route marked stop. When nothing
+// matches the message falls back to the base channel_id as the default
+// route, and without one a warning is printed so it is not lost silently.
+func (c *CLI) selectRoutes(content string) ([]Route, error) {
+	var selected []Route
+	for _, r := range c.config.Routes {
+		ok, err := r.matches(c.config, content)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		selected = append(selected, r)
+		if r.Stop {
+			break
+		}
+	}
+	if len(selected) == 0 {
+		if c.config.ChannelID == "" {
+			fmt.Fprintln(os.Stderr, "warning: no route matched and no channel_id is configured, nothing sent")
+			return nil, nil
+		}
+		selected = []Route{{Name: "default"}}
+	}
+	return selected, nil
+}
+
+// checkRouteKeys rejects --key when a matching route posts through a
+// webhook, which can't edit the messages kept under the key. It runs before
+// anything is delivered so no route gets a message the others refused.
+func (c *CLI) checkRouteKeys(routes []Route) error {
+	if c.key == "" {
+		return nil
+	}
+	for _, r := range routes {
+		if r.Webhook != "" {
+			return configErrorf("route %s: --key is not supported by webhook routes", r.displayName())
+		}
+	}
+	return nil
+}
+
+// sendRoutes delivers the message to every matching route and reports the
+// result of each delivery on stderr.
+func (c *CLI) sendRoutes() ([]RouteResult, error) {
+	if len(c.stdinData) == 0 {
+		return nil, nil // Nothing to send
+	}
+
+	routes, err := c.selectRoutes(string(c.stdinData))
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	if err := c.checkRouteKeys(routes); err != nil {
+		return nil, err
+	}
+
+	var results []RouteResult
+	var firstErr error
+	failed, delivered := 0, false
+	for _, r := range routes {
+		cfg := r.apply(c.config)
+		rc := c.withConfig(cfg)
+		if c.key != "" {
+			// Each route keeps its own set of messages under the key
+			rc.key = c.key + "@" + r.displayName()
+		}
+
+		if c.config.Debug {
+			log.Printf("Delivering to route %s (%s)", r.displayName(), r.target(cfg))
+		}
+
+		start := time.Now()
+		var receipt *Receipt
+		if r.Webhook != "" {
+			receipt, err = rc.sendToWebhook(r.Webhook, r.ThreadID)
+		} else {
+			receipt, err = rc.deliver()
+		}
+		err = c.stopped(err)
+		if receipt == nil && err != nil {
+			receipt = &Receipt{ChannelID: cfg.ChannelID, ThreadID: r.ThreadID}
+		}
+		if receipt != nil {
+			receipt.Route = r.displayName()
+			receipt.finish(start, err)
+			delivered = delivered || receipt.delivered()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		result := RouteResult{Route: r.displayName(), Target: r.target(cfg), Receipt: receipt, Err: err}
+		results = append(results, result)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "route %s -> %s: failed: %v\n", result.Route, result.Target, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "route %s -> %s: delivered\n", result.Route, result.Target)
+		}
+	}
+
+	if failed > 0 {
+		err := fmt.Errorf("%d of %d routes failed: %w", failed, len(routes), firstErr)
+		if delivered {
+			return results, &partialError{err: err}
+		}
+		return results, err
+	}
+	return results, nil
+}
+
+// withConfig returns a shallow copy of the CLI using cfg for delivery.
+func (c *CLI) withConfig(cfg Config) *CLI {
+	clone := *c
+	clone.config = cfg
+	return &clone
+}
+
+// parseWebhookURL extracts the webhook ID and token from a Discord webhook
+// URL of the form https://discord.com/api/webhooks/ID/TOKEN.
+func parseWebhookURL(raw string) (string, string, error) {
+	idx := strings.Index(raw, "/webhooks/")
+	if idx < 0 {
+		return "", "", configErrorf("invalid webhook URL: %s", raw)
+	}
+	parts := strings.Split(strings.Trim(raw[idx+len("/webhooks/"):], "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", configErrorf("invalid webhook URL: missing ID or token")
+	}
+	return parts[0], parts[1], nil
+}
+
+// sendToWebhook posts the message parts through a webhook, optionally into an
+// existing thread. Webhooks carry their own credentials, so a bot token is
+// only needed to resolve mentions by name.
+func (c *CLI) sendToWebhook(webhookURL, threadID string) (*Receipt, error) {
+	id, token, err := parseWebhookURL(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+
+	discord, err := c.newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer discord.Close()
+
+	content, allowed, err := c.withMentions(discord, string(c.stdinData))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := c.splitMessage(content)
+	receipt := &Receipt{ChannelID: c.config.ChannelID, ThreadID: threadID, Parts: len(messages)}
+	for i, msg := range messages {
+		partContent, embeds := c.formatPart(i, msg)
+		params := &discordgo.WebhookParams{
+			Content:         partContent,
+			Embeds:          embeds,
+			Username:        c.config.Username,
+			AllowedMentions: noMentions(),
+		}
+		if i == 0 {
+			params.AllowedMentions = allowed
+		}
+		var sent *discordgo.Message
+		if threadID != "" {
+			sent, err = discord.WebhookThreadExecute(id, token, true, threadID, params)
+		} else {
+			sent, err = discord.WebhookExecute(id, token, true, params)
+		}
+		if err != nil {
+			return receipt, fmt.Errorf("error sending message part %d/%d: %w", i+1, len(messages), err)
+		}
+		if threadID == "" {
+			receipt.ChannelID = sent.ChannelID
+		}
+		receipt.MessageIDs = append(receipt.MessageIDs, sent.ID)
+	}
+	return receipt, nil
+}
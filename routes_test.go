@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"disgo/fakediscord"
+)
+
+func TestRouteMatching(t *testing.T) {
+	cfg := Config{
+		Tags:       []string{"app", "error"},
+		Properties: map[string]string{"env": "prod"},
+	}
+
+	testCases := []struct {
+		name    string
+		route   Route
+		content string
+		expect  bool
+	}{
+		{
+			name:    "Empty match applies to everything",
+			route:   Route{},
+			content: "hello",
+			expect:  true,
+		},
+		{
+			name:    "Any tag matches",
+			route:   Route{Match: RouteMatch{Tags: []string{"critical", "error"}}},
+			content: "hello",
+			expect:  true,
+		},
+		{
+			name:    "Missing tag does not match",
+			route:   Route{Match: RouteMatch{Tags: []string{"critical"}}},
+			content: "hello",
+			expect:  false,
+		},
+		{
+			name:    "Property mismatch does not match",
+			route:   Route{Match: RouteMatch{Properties: map[string]string{"env": "dev"}}},
+			content: "hello",
+			expect:  false,
+		},
+		{
+			name:    "Content regex matches",
+			route:   Route{Match: RouteMatch{Content: `(?i)panic`}},
+			content: "goroutine PANIC: boom",
+			expect:  true,
+		},
+		{
+			name:    "All rules must hold",
+			route:   Route{Match: RouteMatch{Tags: []string{"error"}, Content: `timeout`}},
+			content: "connection refused",
+			expect:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := tc.route.matches(cfg, tc.content)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ok != tc.expect {
+				t.Errorf("Expected match %v, got %v", tc.expect, ok)
+			}
+		})
+	}
+}
+
+func TestSelectRoutesStops(t *testing.T) {
+	cli := NewCLI()
+	cli.config = Config{
+		Tags: []string{"error"},
+		Routes: []Route{
+			{Name: "alerts", ChannelID: "alerts", Match: RouteMatch{Tags: []string{"error"}}, Stop: true},
+			{Name: "logs", ChannelID: "logs"},
+		},
+	}
+
+	routes, err := cli.selectRoutes("boom")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 1 || routes[0].Name != "alerts" {
+		t.Errorf("Expected only the alerts route, got %v", routes)
+	}
+
+	cli.config.Tags = []string{"info"}
+	routes, err = cli.selectRoutes("fine")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 1 || routes[0].Name != "logs" {
+		t.Errorf("Expected only the logs route, got %v", routes)
+	}
+}
+
+func TestSelectRoutesDefault(t *testing.T) {
+	cli := NewCLI()
+	cli.config = Config{
+		Tags:   []string{"info"},
+		Routes: []Route{{Name: "alerts", ChannelID: "alerts", Match: RouteMatch{Tags: []string{"error"}}}},
+	}
+
+	routes, err := cli.selectRoutes("fine")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 0 {
+		t.Errorf("Expected no routes without a channel_id, got %v", routes)
+	}
+
+	cli.config.ChannelID = "base"
+	routes, err = cli.selectRoutes("fine")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 1 || routes[0].displayName() != "default" || routes[0].apply(cli.config).ChannelID != "base" {
+		t.Errorf("Expected the default route to the base channel, got %v", routes)
+	}
+}
+
+func TestRouteApplyOverrides(t *testing.T) {
+	base := Config{
+		ChannelID:    "base",
+		Tags:         []string{"app"},
+		TagMode:      "merge",
+		Properties:   map[string]string{"env": "prod"},
+		PropertyMode: "merge",
+	}
+	route := Route{
+		ThreadID:   "thread",
+		Tags:       []string{"routed"},
+		Properties: map[string]string{"team": "ops"},
+	}
+
+	cfg := route.apply(base)
+	if cfg.ChannelID != "thread" {
+		t.Errorf("Expected thread ID as target, got %s", cfg.ChannelID)
+	}
+	if len(cfg.Tags) != 2 {
+		t.Errorf("Expected merged tags, got %v", cfg.Tags)
+	}
+	if cfg.Properties["env"] != "prod" || cfg.Properties["team"] != "ops" {
+		t.Errorf("Expected merged properties, got %v", cfg.Properties)
+	}
+	if _, ok := base.Properties["team"]; ok {
+		t.Error("Route overrides leaked into the base config")
+	}
+}
+
+func TestParseWebhookURL(t *testing.T) {
+	id, token, err := parseWebhookURL("https://discord.com/api/webhooks/123/abc")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if id != "123" || token != "abc" {
+		t.Errorf("Expected 123/abc, got %s/%s", id, token)
+	}
+
+	if _, _, err := parseWebhookURL("https://example.com/hook"); err == nil {
+		t.Error("Expected error for invalid webhook URL")
+	}
+}
+
+func TestRoutedReceiptsJSON(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	routed := func(content string, dryRun bool) []map[string]interface{} {
+		t.Helper()
+		cli := fakeCLI(srv, content)
+		cli.config.Output = OutputJSON
+		cli.config.Tags = []string{"info"}
+		cli.config.Routes = []Route{
+			{Name: "alerts", ChannelID: "300", Match: RouteMatch{Tags: []string{"error"}}},
+			{Name: "logs", ChannelID: "400", Match: RouteMatch{Tags: []string{"info"}}},
+		}
+		cli.dryRun = dryRun
+		out, err := captureStdout(t, cli.runSend)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var got []map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("Expected a JSON array, got %q: %v", out, err)
+		}
+		return got
+	}
+
+	if got := routed("one route matches", false); len(got) != 1 || got[0]["route"] != "logs" {
+		t.Errorf("Expected one receipt for the logs route in an array, got %v", got)
+	}
+	if got := routed("one route matches", true); len(got) != 1 || got[0]["route"] != "logs" {
+		t.Errorf("Expected one plan for the logs route in an array, got %v", got)
+	}
+
+	cli := fakeCLI(srv, "no route matches")
+	cli.config.Routes = []Route{{Name: "alerts", ChannelID: "300", Match: RouteMatch{Tags: []string{"error"}}}}
+	if _, err := cli.send(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msgs := srv.Messages("100"); len(msgs) != 1 || msgs[0].Content != "no route matches" {
+		t.Errorf("Expected the message in the base channel, got %+v", msgs)
+	}
+}
+
+func TestRouteKeysRejectWebhooks(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := fakeCLI(srv, "deploy started")
+	cli.configPath = t.TempDir()
+	cli.key = "deploy"
+	cli.config.Routes = []Route{
+		{Name: "ops", ChannelID: "300"},
+		{Name: "hook", Webhook: srv.URL + "/api/webhooks/55/secret"},
+	}
+	if _, err := cli.send(); exitCode(err) != ExitConfig {
+		t.Errorf("Expected a config error for --key with a webhook route, got %v", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("Expected nothing to be sent, got %d requests", n)
+	}
+	if _, err := cli.planSend(); exitCode(err) != ExitConfig {
+		t.Errorf("Expected the dry run to reject it too, got %v", err)
+	}
+
+	cli.config.Routes = cli.config.Routes[:1]
+	if _, err := cli.send(); err != nil {
+		t.Errorf("Expected channel routes to keep working with --key, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// errInterrupted is why a run stopped on SIGINT or SIGTERM.
+var errInterrupted = errors.New("interrupted")
+
+// timeoutError is why a run stopped when --timeout ran out.
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.timeout)
+}
+
+// startRun bounds the run by --timeout and cancels it on SIGINT or SIGTERM.
+// Requests made through newClient carry the run's context, so a hung
+// connection is abandoned instead of blocking forever. The returned function
+// releases the timer and signal handler.
+func (c *CLI) startRun() func() {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	c.ctx = ctx
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			// A second signal kills the process as usual
+			signal.Stop(signals)
+			if c.config.Debug {
+				log.Printf("Received %s, cancelling", sig)
+			}
+			cancel(errInterrupted)
+		case <-ctx.Done():
+		}
+	}()
+
+	var timer *time.Timer
+	if c.timeout > 0 {
+		timeout := c.timeout
+		timer = time.AfterFunc(timeout, func() { cancel(&timeoutError{timeout: timeout}) })
+	}
+
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
+// context returns the context of the run, or a background context outside
+// startRun.
+func (c *CLI) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// stopped replaces the transport error of a request cut short by the run
+// ending with the reason it ended, keeping the step that was interrupted.
+func (c *CLI) stopped(err error) error {
+	if err == nil || c.ctx == nil || c.ctx.Err() == nil {
+		return err
+	}
+	cause := context.Cause(c.ctx)
+	if errors.Is(err, cause) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", cause, err)
+}
+
+// contextClient makes every request of a DiscordClient under ctx.
+type contextClient struct {
+	DiscordClient
+	ctx context.Context
+}
+
+// withContext returns a client whose requests are cancelled with ctx.
+func withContext(client DiscordClient, ctx context.Context) DiscordClient {
+	return &contextClient{DiscordClient: client, ctx: ctx}
+}
+
+func (c *contextClient) opts(options []discordgo.RequestOption) []discordgo.RequestOption {
+	return append([]discordgo.RequestOption{discordgo.WithContext(c.ctx)}, options...)
+}
+
+func (c *contextClient) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return c.DiscordClient.ChannelMessageSendComplex(channelID, data, c.opts(options)...)
+}
+
+func (c *contextClient) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return c.DiscordClient.ChannelMessageEditComplex(m, c.opts(options)...)
+}
+
+func (c *contextClient) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	return c.DiscordClient.ChannelMessageDelete(channelID, messageID, c.opts(options)...)
+}
+
+func (c *contextClient) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	return c.DiscordClient.ChannelMessages(channelID, limit, beforeID, afterID, aroundID, c.opts(options)...)
+}
+
+func (c *contextClient) MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return c.DiscordClient.MessageThreadStart(channelID, messageID, name, archiveDuration, c.opts(options)...)
+}
+
+func (c *contextClient) MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error {
+	return c.DiscordClient.MessageReactionAdd(channelID, messageID, emojiID, c.opts(options)...)
+}
+
+func (c *contextClient) MessageReactionRemove(channelID, messageID, emojiID, userID string, options ...discordgo.RequestOption) error {
+	return c.DiscordClient.MessageReactionRemove(channelID, messageID, emojiID, userID, c.opts(options)...)
+}
+
+func (c *contextClient) GuildEmojis(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Emoji, error) {
+	return c.DiscordClient.GuildEmojis(guildID, c.opts(options)...)
+}
+
+func (c *contextClient) GuildMembersSearch(guildID, query string, limit int, options ...discordgo.RequestOption) ([]*discordgo.Member, error) {
+	return c.DiscordClient.GuildMembersSearch(guildID, query, limit, c.opts(options)...)
+}
+
+func (c *contextClient) GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	return c.DiscordClient.GuildRoles(guildID, c.opts(options)...)
+}
+
+func (c *contextClient) WebhookExecute(webhookID, token string, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return c.DiscordClient.WebhookExecute(webhookID, token, wait, data, c.opts(options)...)
+}
+
+func (c *contextClient) WebhookThreadExecute(webhookID, token string, wait bool, threadID string, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return c.DiscordClient.WebhookThreadExecute(webhookID, token, wait, threadID, data, c.opts(options)...)
+}
+
+func (c *contextClient) Gateway(options ...discordgo.RequestOption) (string, error) {
+	return c.DiscordClient.Gateway(c.opts(options)...)
+}
+
+func (c *contextClient) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	return c.DiscordClient.User(userID, c.opts(options)...)
+}
+
+func (c *contextClient) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return c.DiscordClient.Channel(channelID, c.opts(options)...)
+}
+
+func (c *contextClient) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	return c.DiscordClient.UserChannelPermissions(userID, channelID, c.opts(fetchOptions)...)
+}
+
+func (c *contextClient) RequestWithBucketID(method, urlStr string, data interface{}, bucketID string, options ...discordgo.RequestOption) ([]byte, error) {
+	return c.DiscordClient.RequestWithBucketID(method, urlStr, data, bucketID, c.opts(options)...)
+}
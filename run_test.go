@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"disgo/fakediscord"
+)
+
+func TestStopped(t *testing.T) {
+	cli := NewCLI()
+	err := fmt.Errorf("error sending message part 1/1: %w", context.Canceled)
+	if got := cli.stopped(err); got != err {
+		t.Errorf("Expected errors outside a run to be unchanged, got %v", got)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cli.ctx = ctx
+	if got := cli.stopped(err); got != err {
+		t.Errorf("Expected errors of a running run to be unchanged, got %v", got)
+	}
+	cancel(&timeoutError{timeout: time.Second})
+	got := cli.stopped(err)
+	if exitCode(got) != ExitTimeout || !strings.HasPrefix(got.Error(), "timed out after 1s: error sending message part 1/1") {
+		t.Errorf("Expected the timeout as cause, got %v", got)
+	}
+	if again := cli.stopped(got); again != got {
+		t.Errorf("Expected stopped to be idempotent, got %v", again)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	// The second part hangs
+	srv.Inject(fakediscord.Fault{Path: "messages", Delay: time.Minute, After: 1})
+
+	cli := fakeCLI(srv, "part one\npart two\n")
+	cli.config.MaxMessageSize = 10
+	cli.timeout = 100 * time.Millisecond
+	stop := cli.startRun()
+	defer stop()
+
+	start := time.Now()
+	receipt, err := runSendJSON(t, srv, cli)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the timeout to end the send, took %s", elapsed)
+	}
+	if exitCode(err) != ExitTimeout {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "1 of 2 parts delivered") {
+		t.Errorf("Expected the delivered parts in the error, got %v", err)
+	}
+	if receipt == nil || receipt.PartsSent != 1 || receipt.PartsFailed != 1 || !strings.Contains(receipt.Error, "timed out after 100ms") {
+		t.Errorf("Expected a receipt for the delivered part, got %+v", receipt)
+	}
+}
+
+func TestSendInterrupted(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	srv.Inject(fakediscord.Fault{Path: "messages", Delay: time.Minute})
+
+	cli := fakeCLI(srv, "hello")
+	stop := cli.startRun()
+	defer stop()
+
+	go func() {
+		// Wait for the request to be in flight
+		for len(srv.Requests()) == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	}()
+
+	_, err := runSendJSON(t, srv, cli)
+	if exitCode(err) != ExitInterrupted {
+		t.Fatalf("Expected an interruption, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "0 of 1 parts delivered") {
+		t.Errorf("Expected the delivered parts in the error, got %v", err)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultSchedulerInterval is how often scheduler run checks for due
+// messages when --every is not set.
+const DefaultSchedulerInterval = 30 * time.Second
+
+// A scheduled message that fails without delivering anything is retried
+// after scheduleRetryDelay, up to maxScheduleAttempts times in all.
+const (
+	maxScheduleAttempts = 3
+	scheduleRetryDelay  = time.Minute
+)
+
+// ScheduledMessage is a message waiting in the schedule store. It is
+// resolved when scheduled: the content is converted, the level applied and
+// the mentions collected, so it is sent as it would have been then. The
+// config is loaded again at delivery for the token and connection settings.
+// Recurring messages stay in the store with Due moved to the next match of
+// Cron.
+type ScheduledMessage struct {
+	ID         string                    `json:"id"`
+	Due        time.Time                 `json:"due"`
+	Cron       string                    `json:"cron,omitempty"`
+	Config     string                    `json:"config"`
+	ChannelID  string                    `json:"channel_id,omitempty"`
+	ThreadName string                    `json:"thread_name,omitempty"`
+	Content    string                    `json:"content"`
+	Embeds     []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+	LevelEmbed bool                      `json:"level_embed,omitempty"`
+	EmbedColor int                       `json:"embed_color,omitempty"`
+	Mentions   []string                  `json:"mentions,omitempty"`
+	Tags       []string                  `json:"tags,omitempty"`
+	Properties map[string]string         `json:"properties,omitempty"`
+	ReplyTo    string                    `json:"reply_to,omitempty"`
+	Key        string                    `json:"key,omitempty"`
+	React      string                    `json:"react,omitempty"`
+	ReactTo    string                    `json:"react_to,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	Attempts   int                       `json:"attempts,omitempty"`
+	LastError  string                    `json:"last_error,omitempty"`
+}
+
+func (c *CLI) scheduleStatePath() string {
+	return filepath.Join(c.stateDir(), "schedule.json")
+}
+
+// scheduleLayouts are the local time formats --at accepts besides RFC 3339.
+var scheduleLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// parseAt accepts an RFC 3339 time, a local date and time such as
+// 2026-10-17T09:00, or a time of day such as 09:00 for its next occurrence.
+func parseAt(at string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return t, nil
+	}
+	for _, layout := range scheduleLayouts {
+		if t, err := time.ParseInLocation(layout, at, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("15:04", at, now.Location()); err == nil {
+		t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, configErrorf("invalid --at %q (expected a time like 2026-10-17T09:00 or 09:00)", at)
+}
+
+// parseIn accepts a delay such as 90m, 2h or 1d.
+func parseIn(in string) (time.Duration, error) {
+	d, err := parseDays(in)
+	if err != nil || d <= 0 {
+		return 0, configErrorf("invalid --in %q (expected a positive duration like 90m, 2h or 1d)", in)
+	}
+	return d, nil
+}
+
+// parseDays parses a duration, also accepting whole days such as 1d.
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+// scheduled reports whether the send is to be stored instead of sent now.
+func (c *CLI) scheduled() bool {
+	return c.at != "" || c.in != "" || c.cron != ""
+}
+
+// dueTime resolves --at, --in or --cron to when the message is first due.
+func (c *CLI) dueTime(now time.Time) (time.Time, error) {
+	set := 0
+	for _, v := range []string{c.at, c.in, c.cron} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return time.Time{}, configErrorf("use only one of --at, --in and --cron")
+	}
+
+	switch {
+	case c.at != "":
+		due, err := parseAt(c.at, now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !due.After(now) {
+			return time.Time{}, configErrorf("--at %s is in the past", c.at)
+		}
+		return due, nil
+	case c.in != "":
+		d, err := parseIn(c.in)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	default:
+		sched, err := parseCron(c.cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		due := sched.next(now)
+		if due.IsZero() {
+			return time.Time{}, configErrorf("cron expression %q never matches", c.cron)
+		}
+		return due, nil
+	}
+}
+
+func newScheduleID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// updateSchedule applies fn to the stored schedule under the state lock and
+// saves the result, kept in order of due time.
+func (c *CLI) updateSchedule(fn func(store []ScheduledMessage) ([]ScheduledMessage, error)) error {
+	path := c.scheduleStatePath()
+	unlock, err := lockState(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var store []ScheduledMessage
+	if err := loadState(path, &store); err != nil {
+		return err
+	}
+	store, err = fn(store)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(store, func(i, j int) bool { return store[i].Due.Before(store[j].Due) })
+	return saveState(path, store)
+}
+
+// scheduleSend stores the prepared message for the scheduler. The ID is
+// printed on stdout so scripts can cancel it later.
+func (c *CLI) scheduleSend() error {
+	now := time.Now()
+	due, err := c.dueTime(now)
+	if err != nil {
+		return err
+	}
+	mentions, err := c.collectMentions()
+	if err != nil {
+		return err
+	}
+
+	msg := ScheduledMessage{
+		ID:         newScheduleID(),
+		Due:        due,
+		Cron:       c.cron,
+		Config:     c.configName,
+		ChannelID:  c.config.ChannelID,
+		ThreadName: c.config.ThreadName,
+		Content:    string(c.stdinData),
+		Embeds:     c.embeds,
+		LevelEmbed: c.levelEmbed,
+		EmbedColor: c.embedColor,
+		Tags:       c.config.Tags,
+		Properties: c.config.Properties,
+		ReplyTo:    c.replyTo,
+		Key:        c.key,
+		React:      c.react,
+		ReactTo:    c.reactTo,
+		CreatedAt:  now.UTC(),
+	}
+	for _, m := range mentions {
+		msg.Mentions = append(msg.Mentions, m.String())
+	}
+
+	err = c.updateSchedule(func(store []ScheduledMessage) ([]ScheduledMessage, error) {
+		return append(store, msg), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.config.Output == OutputJSON {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("error encoding scheduled message: %w", err)
+		}
+		fmt.Fprintln(c.receiptWriter(), string(data))
+		return nil
+	}
+	fmt.Fprintln(c.receiptWriter(), msg.ID)
+	fmt.Fprintf(os.Stderr, "Scheduled %s for %s%s\n", msg.ID, formatDue(msg.Due), describeRepeat(msg.Cron))
+	return nil
+}
+
+func formatDue(t time.Time) string {
+	return t.Local().Format("Mon 2006-01-02 15:04 MST")
+}
+
+func describeRepeat(cron string) string {
+	if cron == "" {
+		return ""
+	}
+	return ", repeating " + cron
+}
+
+// takeDue removes the messages due at now from the store and returns them.
+// Recurring messages stay, moved to their next occurrence, so a message is
+// never sent twice even if several schedulers run at once.
+func (c *CLI) takeDue(now time.Time) ([]ScheduledMessage, error) {
+	var due []ScheduledMessage
+	err := c.updateSchedule(func(store []ScheduledMessage) ([]ScheduledMessage, error) {
+		var keep []ScheduledMessage
+		for _, m := range store {
+			if m.Due.After(now) {
+				keep = append(keep, m)
+				continue
+			}
+			due = append(due, m)
+			if m.Cron == "" {
+				continue
+			}
+			// Occurrences missed while no scheduler ran are skipped
+			if sched, err := parseCron(m.Cron); err == nil {
+				if next := sched.next(now); !next.IsZero() {
+					m.Due = next
+					m.Attempts = 0
+					keep = append(keep, m)
+				}
+			}
+		}
+		return keep, nil
+	})
+	return due, err
+}
+
+// scheduledCLI returns a CLI that sends msg with the config it was
+// scheduled under. Connection flags given to the scheduler still apply.
+func (c *CLI) scheduledCLI(msg ScheduledMessage) (*CLI, error) {
+	sc := *c
+	sc.configName = msg.Config
+	sc.config = Config{}
+	if err := sc.loadConfig(); err != nil {
+		return nil, &configError{err: err}
+	}
+	sc.mergeFlags()
+
+	sc.config.ChannelID = msg.ChannelID
+	sc.config.ThreadName = msg.ThreadName
+	sc.config.Mentions = msg.Mentions
+	sc.config.TagMentions = nil // already resolved into Mentions
+	sc.config.Tags = msg.Tags
+	sc.config.Properties = msg.Properties
+	sc.config.Passthrough = false
+	sc.stdinData = []byte(msg.Content)
+	sc.embeds = msg.Embeds
+	// Entries saved before level_embed was recorded only had a color
+	sc.levelEmbed = msg.LevelEmbed || msg.EmbedColor != 0
+	sc.embedColor = msg.EmbedColor
+	sc.level = ""
+	sc.replyTo = msg.ReplyTo
+	sc.key = msg.Key
+	sc.react = msg.React
+	sc.reactTo = msg.ReactTo
+	return &sc, nil
+}
+
+// requeue records a failed delivery. A one-off message that delivered
+// nothing is put back to retry later, unless it has used up its attempts;
+// an interrupted one is put back as it was.
+func (c *CLI) requeue(msg ScheduledMessage, err error, now time.Time) (bool, error) {
+	var partErr *partialError
+	retry := false
+	updateErr := c.updateSchedule(func(store []ScheduledMessage) ([]ScheduledMessage, error) {
+		if msg.Cron != "" {
+			for i := range store {
+				if store[i].ID == msg.ID {
+					store[i].LastError = err.Error()
+				}
+			}
+			return store, nil
+		}
+		if errors.As(err, &partErr) {
+			return store, nil
+		}
+		msg.LastError = err.Error()
+		if c.context().Err() == nil {
+			msg.Attempts++
+			msg.Due = now.Add(scheduleRetryDelay)
+		}
+		if msg.Attempts >= maxScheduleAttempts {
+			return store, nil
+		}
+		retry = true
+		return append(store, msg), nil
+	})
+	return retry, updateErr
+}
+
+// runDue sends the messages due at now, printing a line for each, and
+// returns an error describing any that failed.
+func (c *CLI) runDue(now time.Time) error {
+	due, err := c.takeDue(now)
+	if err != nil {
+		return err
+	}
+
+	w := c.receiptWriter()
+	var firstErr error
+	failed := 0
+	for _, msg := range due {
+		if c.config.Debug {
+			log.Printf("Sending scheduled message %s due %s", msg.ID, formatDue(msg.Due))
+		}
+
+		var receipts []*Receipt
+		sc, err := c.scheduledCLI(msg)
+		if err == nil {
+			receipts, err = sc.send()
+		}
+		if err == nil {
+			result := &BatchResult{Status: BatchSent, Receipts: receipts}
+			if len(receipts) == 0 {
+				result.Status = BatchDropped
+			}
+			fmt.Fprintf(w, "✓ %s: %s\n", msg.ID, describeResult(result))
+			continue
+		}
+
+		err = c.stopped(err)
+		failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+		retry, requeueErr := c.requeue(msg, err, now)
+		if requeueErr != nil {
+			return requeueErr
+		}
+		note := ""
+		switch {
+		case retry && c.context().Err() != nil:
+			note = " (will be sent next run)"
+		case retry:
+			note = fmt.Sprintf(" (retrying in %s)", scheduleRetryDelay)
+		case msg.Cron == "":
+			note = " (giving up)"
+		}
+		fmt.Fprintf(w, "✗ %s: %v%s\n", msg.ID, err, note)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scheduled messages failed: %w", failed, len(due), firstErr)
+	}
+	return nil
+}
+
+// runScheduler handles `disgo scheduler run`: deliver due messages every
+// --every until interrupted, or once with --once for cron jobs.
+func (c *CLI) runScheduler() error {
+	if len(c.args) != 1 || c.args[0] != "run" {
+		return configErrorf("usage: disgo scheduler run [--once] [--every 30s]")
+	}
+	if c.once {
+		return c.runDue(time.Now())
+	}
+
+	interval := c.every
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+	// Runs until interrupted or --timeout runs out
+	ctx := c.context()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), errInterrupted) {
+				return nil
+			}
+			return context.Cause(ctx)
+		case <-timer.C:
+			if err := c.runDue(time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error sending scheduled messages: %v\n", err)
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// preview returns the first line of content, shortened to n runes.
+func preview(content string, n int) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if utf8.RuneCountInString(line) <= n {
+		return line
+	}
+	return string([]rune(line)[:n-1]) + "…"
+}
+
+// writeSchedule prints the stored messages as a table.
+func writeSchedule(w io.Writer, store []ScheduledMessage) {
+	if len(store) == 0 {
+		fmt.Fprintln(w, "No scheduled messages")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDUE\tREPEAT\tTARGET\tMESSAGE")
+	for _, m := range store {
+		target := "channel " + m.ChannelID
+		if m.ChannelID == "" {
+			target = "routes"
+		}
+		if m.ThreadName != "" {
+			target += " (thread " + m.ThreadName + ")"
+		}
+		message := preview(m.Content, 40)
+		if m.LastError != "" {
+			message += " [failed: " + m.LastError + "]"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.ID, formatDue(m.Due), m.Cron, target, message)
+	}
+	tw.Flush()
+}
+
+// runSchedule handles `disgo schedule list` and `disgo schedule cancel ID...`.
+func (c *CLI) runSchedule() error {
+	if len(c.args) == 0 {
+		return configErrorf("usage: disgo schedule list|cancel ID...")
+	}
+
+	switch c.args[0] {
+	case "list":
+		var store []ScheduledMessage
+		if err := loadState(c.scheduleStatePath(), &store); err != nil {
+			return err
+		}
+		switch c.config.Output {
+		case OutputJSON:
+			if store == nil {
+				store = []ScheduledMessage{}
+			}
+			data, err := json.Marshal(store)
+			if err != nil {
+				return fmt.Errorf("error encoding schedule: %w", err)
+			}
+			fmt.Println(string(data))
+		case "", OutputText:
+			writeSchedule(os.Stdout, store)
+		default:
+			return configErrorf("unknown output format %q (expected text or json)", c.config.Output)
+		}
+		return nil
+	case "cancel":
+		ids := c.args[1:]
+		if len(ids) == 0 {
+			return configErrorf("usage: disgo schedule cancel ID...")
+		}
+		err := c.updateSchedule(func(store []ScheduledMessage) ([]ScheduledMessage, error) {
+			for _, id := range ids {
+				found := false
+				for i := range store {
+					if store[i].ID == id {
+						store = append(store[:i], store[i+1:]...)
+						found = true
+						break
+					}
+				}
+				if !found {
+					return nil, configErrorf("no scheduled message %q", id)
+				}
+			}
+			return store, nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			fmt.Printf("Cancelled %s\n", id)
+		}
+		return nil
+	}
+	return configErrorf("unknown schedule action %q (expected list or cancel)", c.args[0])
+}
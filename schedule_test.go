@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"disgo/fakediscord"
+)
+
+func TestParseAt(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		at       string
+		expected time.Time
+	}{
+		{"2026-10-18T09:00", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"2026-10-18 09:00", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"2026-10-18T09:00:30", time.Date(2026, 10, 18, 9, 0, 30, 0, time.UTC)},
+		{"2026-10-18T09:00:00+02:00", time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC)},
+		{"11:00", time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)},
+		{"09:00", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		got, err := parseAt(tc.at, now)
+		if err != nil || !got.Equal(tc.expected) {
+			t.Errorf("Expected %s for %q, got %s (%v)", tc.expected, tc.at, got, err)
+		}
+	}
+	if _, err := parseAt("tomorrow", now); exitCode(err) != ExitConfig {
+		t.Errorf("Expected an invalid time to be a config error, got %v", err)
+	}
+}
+
+func TestDueTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		at, in    string
+		cron      string
+		expected  time.Time
+		errSubstr string
+	}{
+		{"in", "", "2h", "", now.Add(2 * time.Hour), ""},
+		{"in days", "", "1d", "", now.Add(24 * time.Hour), ""},
+		{"cron", "", "", "0 9 * * *", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), ""},
+		{"past", "2026-10-17T09:00", "", "", time.Time{}, "in the past"},
+		{"negative", "", "-1h", "", time.Time{}, "invalid --in"},
+		{"both", "11:00", "2h", "", time.Time{}, "only one of"},
+		{"never", "", "", "0 0 31 2 *", time.Time{}, "never matches"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := NewCLI()
+			cli.at, cli.in, cli.cron = tc.at, tc.in, tc.cron
+			got, err := cli.dueTime(now)
+			if tc.errSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errSubstr) {
+					t.Errorf("Expected an error containing %q, got %v", tc.errSubstr, err)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tc.expected) {
+				t.Errorf("Expected %s, got %s (%v)", tc.expected, got, err)
+			}
+		})
+	}
+}
+
+// scheduleCLI returns a CLI whose config directory holds a default config
+// pointing at srv, as the scheduler loads it again at delivery.
+func scheduleCLI(t *testing.T, srv *fakediscord.Server, content string) *CLI {
+	t.Helper()
+	cli := fakeCLI(srv, content)
+	cli.configPath = t.TempDir()
+	config := "token: test-token\nchannel_id: \"100\"\napi_base: " + srv.URL + "\nmessage_mode: serialize\n"
+	if err := os.WriteFile(filepath.Join(cli.configPath, "default.yaml"), []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return cli
+}
+
+func loadSchedule(t *testing.T, cli *CLI) []ScheduledMessage {
+	t.Helper()
+	var store []ScheduledMessage
+	if err := loadState(cli.scheduleStatePath(), &store); err != nil {
+		t.Fatalf("Failed to load schedule: %v", err)
+	}
+	return store
+}
+
+func TestScheduleAndDeliver(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := scheduleCLI(t, srv, "**Standup** in 5 minutes")
+	cli.config.ChannelID = "200"
+	cli.config.Mentions = []string{"role:42"}
+	cli.in = "2h"
+	if err := cli.runSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(srv.Requests()) != 0 {
+		t.Errorf("Expected nothing to be sent when scheduling, got %d requests", len(srv.Requests()))
+	}
+	store := loadSchedule(t, cli)
+	if len(store) != 1 || store[0].ChannelID != "200" || store[0].Mentions[0] != "role:42" || store[0].Config != "default" {
+		t.Fatalf("Expected the resolved message in the store, got %+v", store)
+	}
+
+	scheduler := scheduleCLI(t, srv, "")
+	scheduler.configPath = cli.configPath
+	if err := scheduler.runDue(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(srv.Messages("200")) != 0 {
+		t.Error("Expected nothing to be sent before the message is due")
+	}
+	if err := scheduler.runDue(time.Now().Add(3 * time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msgs := srv.Messages("200")
+	if len(msgs) != 1 || msgs[0].Content != "<@&42>\n**Standup** in 5 minutes" {
+		t.Errorf("Expected the scheduled message with its mention, got %+v", msgs)
+	}
+	if store := loadSchedule(t, cli); len(store) != 0 {
+		t.Errorf("Expected the delivered message to leave the store, got %+v", store)
+	}
+}
+
+func TestScheduleLevelEmbed(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := scheduleCLI(t, srv, "all quiet")
+	cli.config.LevelEmbeds = true
+	cli.config.Levels = map[string]LevelConfig{"info": {Color: "#000000"}}
+	cli.level = "info"
+	cli.in = "1h"
+	if err := cli.runSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	scheduler := scheduleCLI(t, srv, "")
+	scheduler.configPath = cli.configPath
+	if err := scheduler.runDue(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msgs := srv.Messages("100")
+	if len(msgs) != 1 || len(msgs[0].Embeds) != 1 || !strings.Contains(msgs[0].Embeds[0].Description, "all quiet") {
+		t.Errorf("Expected the scheduled message in a black level embed, got %+v", msgs)
+	}
+}
+
+func TestScheduleRecurring(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+
+	cli := scheduleCLI(t, srv, "Standup!")
+	cli.cron = "0 9 * * *"
+	if err := cli.runSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	first := loadSchedule(t, cli)[0].Due
+
+	for i := 0; i < 2; i++ {
+		if err := cli.runDue(first.AddDate(0, 0, i)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if got := len(srv.Messages("100")); got != 2 {
+		t.Errorf("Expected a message per occurrence, got %d", got)
+	}
+	store := loadSchedule(t, cli)
+	if len(store) != 1 || !store[0].Due.Equal(first.AddDate(0, 0, 2)) {
+		t.Errorf("Expected the message to move to the next occurrence, got %+v", store)
+	}
+}
+
+func TestScheduleRetries(t *testing.T) {
+	srv := fakediscord.New()
+	defer srv.Close()
+	srv.Inject(fakediscord.Fault{Status: http.StatusInternalServerError, Times: 3})
+
+	cli := scheduleCLI(t, srv, "hello")
+	cli.in = "1m"
+	if err := cli.runSend(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	now := time.Now().Add(2 * time.Minute)
+	for attempt := 1; attempt <= maxScheduleAttempts; attempt++ {
+		if err := cli.runDue(now); err == nil {
+			t.Fatalf("Expected attempt %d to fail", attempt)
+		}
+		store := loadSchedule(t, cli)
+		if attempt < maxScheduleAttempts {
+			if len(store) != 1 || store[0].Attempts != attempt || !store[0].Due.Equal(now.Add(scheduleRetryDelay)) || store[0].LastError == "" {
+				t.Fatalf("Expected a retry after attempt %d, got %+v", attempt, store)
+			}
+		} else if len(store) != 0 {
+			t.Errorf("Expected the message to be dropped after %d attempts, got %+v", attempt, store)
+		}
+		now = now.Add(scheduleRetryDelay)
+	}
+}
+
+func TestScheduleListAndCancel(t *testing.T) {
+	cli := NewCLI()
+	cli.configPath = t.TempDir()
+	due := time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local)
+	saveState(cli.scheduleStatePath(), []ScheduledMessage{
+		{ID: "aaaa1111", Due: due, Cron: "0 9 * * 1-5", ChannelID: "100", Content: "Standup time!\nJoin the call"},
+		{ID: "bbbb2222", Due: due.Add(time.Hour), ChannelID: "200", ThreadName: "ops", Content: strings.Repeat("x", 50)},
+	})
+
+	var buf bytes.Buffer
+	var store []ScheduledMessage
+	loadState(cli.scheduleStatePath(), &store)
+	writeSchedule(&buf, store)
+	out := buf.String()
+	if !strings.Contains(out, "aaaa1111  "+formatDue(due)+"  0 9 * * 1-5  channel 100") || !strings.Contains(out, "Standup time!\n") {
+		t.Errorf("Expected a row per message, got:\n%s", out)
+	}
+	if !strings.Contains(out, "channel 200 (thread ops)") || !strings.Contains(out, strings.Repeat("x", 39)+"…") {
+		t.Errorf("Expected the thread and a shortened preview, got:\n%s", out)
+	}
+
+	cli.args = []string{"cancel", "aaaa1111"}
+	if err := cli.runSchedule(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if store := loadSchedule(t, cli); len(store) != 1 || store[0].ID != "bbbb2222" {
+		t.Errorf("Expected only the other message to remain, got %+v", store)
+	}
+	cli.args = []string{"cancel", "aaaa1111"}
+	if err := cli.runSchedule(); exitCode(err) != ExitConfig {
+		t.Errorf("Expected an unknown ID to be a config error, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "disgo-state")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "nested", "keys.json")
+
+	// Missing files load as empty state
+	keys := make(map[string]KeyedMessage)
+	if err := loadState(path, &keys); err != nil {
+		t.Fatalf("Failed to load missing state: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("Expected empty state, got %v", keys)
+	}
+
+	keys["deploy"] = KeyedMessage{ChannelID: "chan", MessageIDs: []string{"1", "2"}}
+	if err := saveState(path, keys); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	loaded := make(map[string]KeyedMessage)
+	if err := loadState(path, &loaded); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if len(loaded["deploy"].MessageIDs) != 2 {
+		t.Errorf("Expected 2 message IDs, got %v", loaded["deploy"])
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the state file, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Structured input formats.
+const (
+	FormatTable  = "table"  // aligned monospace table in a code block
+	FormatFields = "fields" // one embed per record with a field per column
+)
+
+// DefaultMaxColumnWidth bounds table cells when max_column_width is unset.
+const DefaultMaxColumnWidth = 40
+
+// Discord limits that apply to embed field rendering.
+const (
+	maxEmbedsPerMessage = 10
+	maxFieldsPerEmbed   = 25
+	maxFieldNameLength  = 256
+	maxFieldValueLength = 1024
+	maxEmbedTextLength  = 6000 // across every embed of a message
+	// captionReserve is kept free of maxEmbedTextLength for the caption,
+	// which goes into an embed of its own when level embeds are on
+	captionReserve = 200
+)
+
+// records is tabular data: column names and rows of cells.
+type records struct {
+	headers []string
+	rows    [][]string
+}
+
+// parseRecords reads a JSON array, NDJSON, TSV or CSV. Objects become rows
+// with a column per key in order of first appearance; for arrays of arrays,
+// TSV and CSV the first row is the header.
+func parseRecords(content string) (*records, error) {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return nil, fmt.Errorf("no data to format")
+	}
+	if trimmed[0] == '[' || trimmed[0] == '{' {
+		return parseJSONRecords(trimmed)
+	}
+
+	firstLine, _, _ := strings.Cut(trimmed, "\n")
+	r := csv.NewReader(strings.NewReader(trimmed))
+	if strings.Contains(firstLine, "\t") {
+		r.Comma = '\t'
+	}
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing delimited data: %w", err)
+	}
+	return &records{headers: rows[0], rows: rows[1:]}, nil
+}
+
+// parseJSONRecords reads a JSON array or a stream of JSON values, keeping
+// object keys in document order.
+func parseJSONRecords(content string) (*records, error) {
+	var values []json.RawMessage
+	if content[0] == '[' {
+		if err := json.Unmarshal([]byte(content), &values); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(strings.NewReader(content))
+		for {
+			var v json.RawMessage
+			if err := dec.Decode(&v); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("error parsing JSON: %w", err)
+			}
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no data to format")
+	}
+
+	switch bytes.TrimSpace(values[0])[0] {
+	case '{':
+		rec := &records{}
+		index := make(map[string]int)
+		for _, v := range values {
+			keys, cells, err := objectCells(v)
+			if err != nil {
+				return nil, err
+			}
+			row := make([]string, len(rec.headers))
+			for i, key := range keys {
+				col, ok := index[key]
+				if !ok {
+					col = len(rec.headers)
+					index[key] = col
+					rec.headers = append(rec.headers, key)
+					row = append(row, "")
+				}
+				row[col] = cells[i]
+			}
+			rec.rows = append(rec.rows, row)
+		}
+		return rec, nil
+	case '[':
+		var rows [][]string
+		for _, v := range values {
+			var items []json.RawMessage
+			if err := json.Unmarshal(v, &items); err != nil {
+				return nil, fmt.Errorf("error parsing JSON: rows must all be arrays")
+			}
+			row := make([]string, len(items))
+			for i, item := range items {
+				row[i] = jsonCell(item)
+			}
+			rows = append(rows, row)
+		}
+		return &records{headers: rows[0], rows: rows[1:]}, nil
+	}
+
+	rec := &records{headers: []string{"value"}}
+	for _, v := range values {
+		rec.rows = append(rec.rows, []string{jsonCell(v)})
+	}
+	return rec, nil
+}
+
+// objectCells returns the keys of a JSON object in document order with their
+// values rendered as cells.
+func objectCells(data json.RawMessage) ([]string, []string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("error parsing JSON: records must all be objects")
+	}
+	var keys, cells []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing JSON: %w", err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("error parsing JSON: records must all be objects")
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, fmt.Errorf("error parsing JSON: %w", err)
+		}
+		keys = append(keys, key)
+		cells = append(cells, jsonCell(value))
+	}
+	return keys, cells, nil
+}
+
+// jsonCell renders a JSON value for a table cell: strings unquoted, null
+// empty and nested values as compact JSON.
+func jsonCell(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	trimmed := bytes.TrimSpace(v)
+	if string(trimmed) == "null" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, trimmed); err != nil {
+		return string(trimmed)
+	}
+	return buf.String()
+}
+
+// cleanCell flattens a cell onto one line so every row stays a single line
+// and limits it to maxWidth characters.
+func cleanCell(s string, maxWidth int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if maxWidth > 0 && displayWidth(s) > maxWidth {
+		width := 0
+		for i, r := range s {
+			if width+runeWidth(r) > maxWidth-1 {
+				s = s[:i]
+				break
+			}
+			width += runeWidth(r)
+		}
+		s += "…"
+	}
+	return s
+}
+
+// wideRanges are the characters a monospace font shows two columns wide:
+// East Asian wide and fullwidth characters and emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0},
+	{0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2648, 0x2653},
+	{0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1}, {0x26AA, 0x26AB},
+	{0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4},
+	{0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5}, {0x26FA, 0x26FA},
+	{0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B}, {0x2728, 0x2728},
+	{0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757},
+	{0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF}, {0x2B1B, 0x2B1C},
+	{0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E}, {0x3041, 0x33FF},
+	{0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F},
+	{0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F2FF}, {0x1F300, 0x1F64F},
+	{0x1F680, 0x1F6FF}, {0x1F7E0, 0x1F7EB}, {0x1F900, 0x1F9FF}, {0x1FA70, 0x1FAFF},
+	{0x20000, 0x3FFFD},
+}
+
+// runeWidth returns the number of monospace columns r takes. Combining
+// marks, joiners and variation selectors take none.
+func runeWidth(r rune) int {
+	if r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F) || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wr := range wideRanges {
+		if r < wr[0] {
+			break
+		}
+		if r <= wr[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth returns the number of monospace columns s takes, so that
+// tables with CJK text or emoji stay aligned.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// isNumeric reports whether every non-empty cell in the column is a number.
+func isNumeric(rows [][]string, col int) bool {
+	seen := false
+	for _, row := range rows {
+		if col >= len(row) || row[col] == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(row[col], 64); err != nil {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+// renderTable lays the records out as aligned columns under a header line.
+// Numeric columns are right-aligned, and widths are display columns so that
+// CJK text and emoji line up. Each row is a single line so splitting never
+// cuts a row in half.
+func renderTable(rec *records, maxWidth int) string {
+	cols := len(rec.headers)
+	for _, row := range rec.rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+
+	cell := func(row []string, i int) string {
+		if i < len(row) {
+			return cleanCell(row[i], maxWidth)
+		}
+		return ""
+	}
+
+	widths := make([]int, cols)
+	numeric := make([]bool, cols)
+	for i := range widths {
+		widths[i] = displayWidth(cell(rec.headers, i))
+		for _, row := range rec.rows {
+			if w := displayWidth(cell(row, i)); w > widths[i] {
+				widths[i] = w
+			}
+		}
+		numeric[i] = isNumeric(rec.rows, i)
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		var line strings.Builder
+		for i := 0; i < cols; i++ {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			text := cell(row, i)
+			pad := strings.Repeat(" ", widths[i]-displayWidth(text))
+			if numeric[i] {
+				line.WriteString(pad + text)
+			} else {
+				line.WriteString(text + pad)
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+
+	writeRow(rec.headers)
+	rule := make([]string, cols)
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+	b.WriteString(strings.Join(rule, "  "))
+	b.WriteByte('\n')
+	for _, row := range rec.rows {
+		writeRow(row)
+	}
+	return b.String()
+}
+
+// renderFields turns each record into an embed with an inline field per
+// column. It returns false when the records don't fit in one message; one
+// embed and some of the text limit are kept free for the caption when level
+// embeds are on.
+func renderFields(rec *records) ([]*discordgo.MessageEmbed, bool) {
+	if len(rec.rows) > maxEmbedsPerMessage-1 || len(rec.headers) > maxFieldsPerEmbed {
+		return nil, false
+	}
+
+	var embeds []*discordgo.MessageEmbed
+	total := 0
+	for _, row := range rec.rows {
+		embed := &discordgo.MessageEmbed{}
+		for i, name := range rec.headers {
+			value := ""
+			if i < len(row) {
+				value = row[i]
+			}
+			if value == "" {
+				// Discord rejects empty field values
+				value = "-"
+			}
+			if name == "" {
+				name = "-"
+			}
+			field := &discordgo.MessageEmbedField{
+				Name:   cleanCell(name, maxFieldNameLength),
+				Value:  truncateRunes(value, maxFieldValueLength),
+				Inline: true,
+			}
+			total += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+			embed.Fields = append(embed.Fields, field)
+		}
+		embeds = append(embeds, embed)
+	}
+	if total > maxEmbedTextLength-captionReserve {
+		return nil, false
+	}
+	return embeds, true
+}
+
+// truncateRunes limits s to n characters, marking the cut with an ellipsis.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func (c *CLI) getEffectiveMaxColumnWidth() int {
+	if c.config.MaxColumnWidth <= 0 {
+		return DefaultMaxColumnWidth
+	}
+	return c.config.MaxColumnWidth
+}
+
+// formatRecords renders structured input according to --format. Fields are
+// attached as embeds below a short caption; record sets too large for embeds
+// fall back to a table.
+func (c *CLI) formatRecords(content string) (string, error) {
+	switch c.config.Format {
+	case FormatTable, FormatFields:
+	default:
+		return "", configErrorf("invalid format %q (expected table or fields)", c.config.Format)
+	}
+
+	rec, err := parseRecords(content)
+	if err != nil {
+		return "", err
+	}
+
+	if c.config.Format == FormatFields {
+		if embeds, ok := renderFields(rec); ok {
+			c.embeds = embeds
+			return fmt.Sprintf("📋 %d record%s", len(rec.rows), plural(len(rec.rows))), nil
+		}
+		if c.config.Debug {
+			log.Printf("%d records with %d columns don't fit in embeds, rendering a table", len(rec.rows), len(rec.headers))
+		}
+	}
+	return wrapCode(renderTable(rec, c.getEffectiveMaxColumnWidth()), ""), nil
+}
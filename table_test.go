@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		headers []string
+		rows    [][]string
+	}{
+		{
+			name:    "csv",
+			input:   "name,status\nweb-1,Running\n\"db, primary\",Pending\n",
+			headers: []string{"name", "status"},
+			rows:    [][]string{{"web-1", "Running"}, {"db, primary", "Pending"}},
+		},
+		{
+			name:    "tsv",
+			input:   "name\tready\nweb-1\t1/1\n",
+			headers: []string{"name", "ready"},
+			rows:    [][]string{{"web-1", "1/1"}},
+		},
+		{
+			name:    "json objects keep key order and merge keys",
+			input:   `[{"name": "web-1", "restarts": 0}, {"name": "db", "node": null, "labels": {"a": "b"}}]`,
+			headers: []string{"name", "restarts", "node", "labels"},
+			rows:    [][]string{{"web-1", "0"}, {"db", "", "", `{"a":"b"}`}},
+		},
+		{
+			name:    "ndjson",
+			input:   "{\"id\": 1}\n{\"id\": 2}\n",
+			headers: []string{"id"},
+			rows:    [][]string{{"1"}, {"2"}},
+		},
+		{
+			name:    "json arrays",
+			input:   `[["a", "b"], [1, true]]`,
+			headers: []string{"a", "b"},
+			rows:    [][]string{{"1", "true"}},
+		},
+		{
+			name:    "json scalars",
+			input:   `["x", "y"]`,
+			headers: []string{"value"},
+			rows:    [][]string{{"x"}, {"y"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, err := parseRecords(tc.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rec.headers, tc.headers) {
+				t.Errorf("Expected headers %v, got %v", tc.headers, rec.headers)
+			}
+			if !reflect.DeepEqual(rec.rows, tc.rows) {
+				t.Errorf("Expected rows %q, got %q", tc.rows, rec.rows)
+			}
+		})
+	}
+
+	for _, input := range []string{"", "[", `[{"a": 1}, 2]`} {
+		if _, err := parseRecords(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	rec := &records{
+		headers: []string{"name", "restarts", "message"},
+		rows: [][]string{
+			{"web-1", "0", "ok"},
+			{"db-primary", "12", "a very long\nmessage that goes on"},
+		},
+	}
+
+	got := renderTable(rec, 10)
+	expected := "" +
+		"name        restarts  message\n" +
+		"----------  --------  ----------\n" +
+		"web-1              0  ok\n" +
+		"db-primary        12  a very lo…\n"
+	if got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestRenderTableWideCharacters(t *testing.T) {
+	rec := &records{
+		headers: []string{"name", "status"},
+		rows: [][]string{
+			{"東京", "✅ up"},
+			{"osaka", "down"},
+			{"東京都千代田区丸の内", "ok"},
+		},
+	}
+
+	got := renderTable(rec, 8)
+	expected := "" +
+		"name     status\n" +
+		"-------  ------\n" +
+		"東京     ✅ up\n" +
+		"osaka    down\n" +
+		"東京都…  ok\n"
+	if got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestFormatRecordsTableSplitsOnRows(t *testing.T) {
+	cli := NewCLI()
+	cli.config.Format = FormatTable
+	cli.config.MaxMessageSize = 120
+	cli.config.MessageMode = ModeSerialize
+
+	input := "host,status\n" + strings.Repeat("server-with-long-name,healthy\n", 12)
+	content, err := cli.formatRecords(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	parts := cli.splitMessage(content)
+	if len(parts) < 2 {
+		t.Fatalf("Expected several parts, got %d", len(parts))
+	}
+	rows := 0
+	for i, part := range parts {
+		if len(part) > 120 {
+			t.Errorf("Part %d exceeds budget: %d", i, len(part))
+		}
+		for _, line := range strings.Split(part, "\n") {
+			if strings.HasPrefix(line, "server") {
+				if line != "server-with-long-name  healthy" {
+					t.Errorf("Part %d has a cut row: %q", i, line)
+				}
+				rows++
+			}
+		}
+	}
+	if rows != 12 {
+		t.Errorf("Expected 12 rows, got %d", rows)
+	}
+}
+
+func TestFormatRecordsFields(t *testing.T) {
+	cli := NewCLI()
+	cli.config.Format = FormatFields
+
+	content, err := cli.formatRecords(`[{"name": "web-1", "status": ""}, {"name": "db"}]`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content != "📋 2 records" {
+		t.Errorf("Expected a caption, got %q", content)
+	}
+	if len(cli.embeds) != 2 || len(cli.embeds[0].Fields) != 2 {
+		t.Fatalf("Expected 2 embeds with 2 fields, got %+v", cli.embeds)
+	}
+	if f := cli.embeds[0].Fields[1]; f.Name != "status" || f.Value != "-" || !f.Inline {
+		t.Errorf("Expected an inline placeholder for the empty value, got %+v", f)
+	}
+
+	_, embeds := cli.formatPart(0, content)
+	if len(embeds) != 2 {
+		t.Errorf("Expected record embeds on the first part, got %d", len(embeds))
+	}
+	if _, embeds := cli.formatPart(1, content); len(embeds) != 0 {
+		t.Errorf("Expected no record embeds on later parts, got %d", len(embeds))
+	}
+
+	// Too many records for one message fall back to a table
+	cli = NewCLI()
+	cli.config.Format = FormatFields
+	content, err = cli.formatRecords("n\n" + strings.Repeat("1\n", maxEmbedsPerMessage))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(content, "```\nn\n-\n") || cli.embeds != nil {
+		t.Errorf("Expected a table fallback, got %q", content)
+	}
+
+	// So do records over Discord's total embed text limit
+	cli = NewCLI()
+	cli.config.Format = FormatFields
+	cli.config.MaxColumnWidth = 10
+	row := fmt.Sprintf(`{"a": %q, "b": %q}`, strings.Repeat("x", 900), strings.Repeat("y", 900))
+	content, err = cli.formatRecords("[" + strings.Repeat(row+",", 3) + row + "]")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(content, "```") || cli.embeds != nil {
+		t.Errorf("Expected a table fallback for 7200 characters of fields, got %d embeds", len(cli.embeds))
+	}
+}
+
+func TestFormatRecordsInvalidFormat(t *testing.T) {
+	cli := NewCLI()
+	cli.config.Format = "xml"
+	if _, err := cli.formatRecords("a,b"); err == nil {
+		t.Error("Expected an invalid format to be rejected")
+	}
+}
+
+func TestFormatWithInputFormat(t *testing.T) {
+	cli := NewCLI()
+	cli.config.Format = FormatFields
+	cli.config.InputFormat = InputHTML
+	cli.stdinData = []byte(`<p><img src="https://example.com/a.png"></p>`)
+	if err := cli.prepareContent(); exitCode(err) != ExitConfig {
+		t.Errorf("Expected a config error combining --input-format and --format, got %v", err)
+	}
+}
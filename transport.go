@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+// DiscordClient is the part of the Discord REST API used to send, edit, react
+// to and read messages. *discordgo.Session satisfies it. Commands that need
+// the gateway (ask, listen, bot) use a session directly.
+type DiscordClient interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error
+	MessageReactionRemove(channelID, messageID, emojiID, userID string, options ...discordgo.RequestOption) error
+	GuildEmojis(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Emoji, error)
+	GuildMembersSearch(guildID, query string, limit int, options ...discordgo.RequestOption) ([]*discordgo.Member, error)
+	GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error)
+	WebhookExecute(webhookID, token string, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	WebhookThreadExecute(webhookID, token string, wait bool, threadID string, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	Gateway(options ...discordgo.RequestOption) (string, error)
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error)
+	RequestWithBucketID(method, urlStr string, data interface{}, bucketID string, options ...discordgo.RequestOption) ([]byte, error)
+	Close() error
+}
+
+var _ DiscordClient = (*discordgo.Session)(nil)
+
+// discordHost is the host discordgo sends every REST request to.
+const discordHost = "discord.com"
+
+// DefaultRequestTimeout bounds each API request when request_timeout is
+// unset, matching discordgo's own default.
+const DefaultRequestTimeout = 20 * time.Second
+
+// Left to itself discordgo retries rate-limited requests forever. disgo
+// retries a few times and then gives up, so scripts get a clear failure.
+const (
+	maxRateLimitRetries = 3
+	maxRateLimitWait    = time.Minute
+)
+
+// newClient returns the client REST-only commands talk to: the one set on
+// the CLI if any, otherwise a new session. Within a run its requests are
+// cancelled when the run times out or is interrupted.
+func (c *CLI) newClient() (DiscordClient, error) {
+	client := c.client
+	if client == nil {
+		session, err := c.newSession()
+		if err != nil {
+			return nil, err
+		}
+		client = session
+	}
+	if c.ctx != nil {
+		client = withContext(client, c.ctx)
+	}
+	return client, nil
+}
+
+// parseProxy checks a proxy URL: http, https, socks5 or socks5h, with
+// optional user:password credentials.
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		// Don't echo the value, it may hold a password
+		return nil, configErrorf("invalid proxy URL (expected a URL like http://host:3128 or socks5://host:1080)")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, configErrorf("unsupported proxy scheme %q (expected http, https, socks5 or socks5h)", u.Scheme)
+	}
+	return u, nil
+}
+
+// gatewayProxy sets the proxy of the gateway dialer, which only knows http
+// and socks5 proxies. Its socks5 client already leaves name resolution to
+// the proxy, as socks5h asks; an https proxy is reached as an http proxy
+// over a TLS connection.
+func gatewayProxy(dialer *websocket.Dialer, u *url.URL, tlsConfig *tls.Config) {
+	proxyURL := *u
+	switch u.Scheme {
+	case "socks5h":
+		proxyURL.Scheme = "socks5"
+	case "https":
+		proxyURL.Scheme = "http"
+		if proxyURL.Port() == "" {
+			proxyURL.Host = net.JoinHostPort(proxyURL.Hostname(), "443")
+		}
+		proxyAddr := proxyURL.Host
+
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if tlsConfig != nil {
+			cfg = tlsConfig.Clone()
+		}
+		cfg.ServerName = u.Hostname()
+		netDial := dialer.NetDialContext
+		if netDial == nil {
+			netDial = (&net.Dialer{}).DialContext
+		}
+		// With a proxy set, the dialer only ever connects to the proxy
+		dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := netDial(ctx, network, addr)
+			if err != nil || addr != proxyAddr {
+				return conn, err
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+	dialer.Proxy = http.ProxyURL(&proxyURL)
+}
+
+// loadCAFiles returns the system roots plus the certificates in the given
+// PEM bundles.
+func loadCAFiles(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, configErrorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, configErrorf("no PEM certificates found in CA bundle %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// httpTransport builds the transport for API and gateway connections from
+// the proxy, CA bundle and connect timeout settings. Without a configured
+// proxy the usual HTTPS_PROXY and NO_PROXY environment variables apply.
+func (c *CLI) httpTransport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.config.Proxy != "" {
+		u, err := parseProxy(c.config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+		if c.config.Debug {
+			log.Printf("Using proxy %s", u.Redacted())
+		}
+	}
+
+	if len(c.config.CAFiles) > 0 {
+		pool, err := loadCAFiles(c.config.CAFiles)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	if c.config.ConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: c.config.ConnectTimeout, KeepAlive: 30 * time.Second}
+		t.DialContext = dialer.DialContext
+		t.TLSHandshakeTimeout = c.config.ConnectTimeout
+	}
+	return t, nil
+}
+
+// configureHTTP applies the network settings to the session's API client
+// and gateway dialer.
+func (c *CLI) configureHTTP(discord *discordgo.Session) error {
+	t, err := c.httpTransport()
+	if err != nil {
+		return err
+	}
+	timeout := c.config.RequestTimeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	discord.Client = &http.Client{Timeout: timeout, Transport: t}
+
+	// The default dialer is shared, so configure a copy
+	dialer := *discord.Dialer
+	dialer.Proxy = t.Proxy
+	dialer.TLSClientConfig = t.TLSClientConfig
+	if c.config.ConnectTimeout > 0 {
+		dialer.HandshakeTimeout = c.config.ConnectTimeout
+	}
+	if c.config.Proxy != "" {
+		// Already checked by httpTransport
+		u, _ := parseProxy(c.config.Proxy)
+		gatewayProxy(&dialer, u, t.TLSClientConfig)
+	}
+	discord.Dialer = &dialer
+
+	if c.config.APIBase != "" {
+		return useAPIBase(discord, c.config.APIBase)
+	}
+	return nil
+}
+
+// apiBaseTransport sends requests meant for discord.com to another server,
+// keeping the path, so https://discord.com/api/v9/... becomes BASE/api/v9/...
+type apiBaseTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+func (t *apiBaseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != discordHost {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.base.Scheme
+	req.URL.Host = t.base.Host
+	req.URL.Path = strings.TrimSuffix(t.base.Path, "/") + req.URL.Path
+	if req.URL.RawPath != "" {
+		req.URL.RawPath = strings.TrimSuffix(t.base.EscapedPath(), "/") + req.URL.RawPath
+	}
+	req.Host = ""
+	return t.next.RoundTrip(req)
+}
+
+// parseAPIBase checks an api_base value: an http or https URL without a
+// query.
+func parseAPIBase(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return nil, configErrorf("invalid api_base %q (expected an http or https URL)", s)
+	}
+	return u, nil
+}
+
+// useAPIBase points the session's REST requests at base.
+func useAPIBase(discord *discordgo.Session, base string) error {
+	u, err := parseAPIBase(base)
+	if err != nil {
+		return err
+	}
+	next := discord.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	discord.Client.Transport = &apiBaseTransport{base: u, next: next}
+	return nil
+}
+
+// limitRateLimitRetries makes the session give up on requests that are
+// still rate limited after maxRateLimitRetries retries.
+func limitRateLimitRetries(discord *discordgo.Session, debug bool) {
+	next := discord.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	discord.ShouldRetryOnRateLimit = false
+	discord.Client.Transport = &rateLimitTransport{next: next, debug: debug}
+}
+
+// rateLimitTransport waits out 429 responses and retries, up to
+// maxRateLimitRetries times. The last 429 is passed on for discordgo to
+// report as a RateLimitError.
+type rateLimitTransport struct {
+	next  http.RoundTripper
+	debug bool
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt == maxRateLimitRetries {
+			return resp, err
+		}
+
+		wait := retryAfter(resp)
+		if wait > maxRateLimitWait || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if t.debug {
+			log.Printf("Rate limited on %s %s, retrying in %s", req.Method, req.URL.Path, wait)
+		}
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryAfter reads how long to wait from a 429 response: retry_after in the
+// body, in seconds, or else the Retry-After header. The body is left
+// readable.
+func retryAfter(resp *http.Response) time.Duration {
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	var body struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.RetryAfter > 0 {
+		return time.Duration(body.RetryAfter * float64(time.Second))
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	return time.Second
+}